@@ -0,0 +1,219 @@
+// Package api serves the store as a JSON REST API over net/http.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Tinee/tewq/dynamodb"
+)
+
+// maxBodySize is the largest request body the API reads.
+const maxBodySize = 1 << 20
+
+// idempotencyKeyHeader carries the key clients retry a write with, so it's only done once.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// Store is what the API needs from the store, *dynamodb.DynamoDB satisfies it.
+type Store interface {
+	AddProduct(p dynamodb.Product, opts ...dynamodb.WriteOption) (dynamodb.Product, error)
+	GetProduct(id dynamodb.SortableID) (dynamodb.Product, error)
+	DeleteProduct(id dynamodb.SortableID) error
+	AddOptionToProduct(id dynamodb.SortableID, option dynamodb.Option) (dynamodb.Option, error)
+	GetOption(productID, optionID dynamodb.SortableID) (dynamodb.Option, error)
+	UpdateOption(productID, optionID dynamodb.SortableID, input dynamodb.UpdateOptionInput) (dynamodb.Option, error)
+	DeleteOption(productID, optionID dynamodb.SortableID) error
+	SetOptionStock(productID, optionID dynamodb.SortableID, stock int) (dynamodb.Option, error)
+	ArchiveProduct(id dynamodb.SortableID) (dynamodb.Product, error)
+	RestoreProduct(id dynamodb.SortableID) (dynamodb.Product, error)
+	ArchiveOption(productID, optionID dynamodb.SortableID) (dynamodb.Option, error)
+	RestoreOption(productID, optionID dynamodb.SortableID) (dynamodb.Option, error)
+	GetProductsByCategory(input *dynamodb.GetProductsByCategoryInput) ([]dynamodb.Product, dynamodb.PaginationKey, error)
+	SearchProducts(input *dynamodb.SearchProductsInput) ([]dynamodb.SearchResult, error)
+
+	AddBasketItem(item dynamodb.BasketItem, opts ...dynamodb.WriteOption) error
+	GetBasketItems(customerID dynamodb.SortableID) ([]dynamodb.BasketItem, error)
+	GetBasketSummary(customerID dynamodb.SortableID) (dynamodb.BasketSummary, error)
+	Checkout(customerID dynamodb.SortableID) (dynamodb.Order, error)
+}
+
+// Handler routes the requests of the API to the store.
+type Handler struct {
+	store  Store
+	routes []route
+}
+
+// route is an endpoint of the API.
+// Segments of the pattern starting with ":" match any value, which is handed to handle in order.
+type route struct {
+	method  string
+	pattern []string
+	handle  func(w http.ResponseWriter, r *http.Request, params []string) error
+}
+
+// New creates the API handler over store.
+func New(store Store) *Handler {
+	h := &Handler{store: store}
+	h.routes = []route{
+		{http.MethodPost, []string{"products"}, h.addProduct},
+		{http.MethodGet, []string{"products", "search"}, h.searchProducts},
+		{http.MethodGet, []string{"products", ":id"}, h.getProduct},
+		{http.MethodDelete, []string{"products", ":id"}, h.deleteProduct},
+		{http.MethodPost, []string{"products", ":id", "options"}, h.addOption},
+		{http.MethodPost, []string{"products", ":id", "archive"}, h.archiveProduct},
+		{http.MethodPost, []string{"products", ":id", "restore"}, h.restoreProduct},
+		{http.MethodGet, []string{"products", ":id", "options", ":optionId"}, h.getOption},
+		{http.MethodPatch, []string{"products", ":id", "options", ":optionId"}, h.updateOption},
+		{http.MethodDelete, []string{"products", ":id", "options", ":optionId"}, h.deleteOption},
+		{http.MethodPut, []string{"products", ":id", "options", ":optionId", "stock"}, h.setOptionStock},
+		{http.MethodPost, []string{"products", ":id", "options", ":optionId", "archive"}, h.archiveOption},
+		{http.MethodPost, []string{"products", ":id", "options", ":optionId", "restore"}, h.restoreOption},
+		{http.MethodGet, []string{"categories", ":category", "products"}, h.getProductsByCategory},
+		{http.MethodGet, []string{"baskets", ":customerId"}, h.getBasketSummary},
+		{http.MethodGet, []string{"baskets", ":customerId", "items"}, h.getBasketItems},
+		{http.MethodPost, []string{"baskets", ":customerId", "items"}, h.addBasketItem},
+		{http.MethodPost, []string{"baskets", ":customerId", "checkout"}, h.checkout},
+	}
+	return h
+}
+
+// ServeHTTP satisfies the http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	var allowed []string
+	for _, rt := range h.routes {
+		params, ok := rt.match(segments)
+		if !ok {
+			continue
+		}
+		if rt.method != r.Method {
+			allowed = append(allowed, rt.method)
+			continue
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		if err := rt.handle(w, r, params); err != nil {
+			writeError(w, err)
+		}
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+	writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found"})
+}
+
+func (rt route) match(segments []string) ([]string, bool) {
+	if len(segments) != len(rt.pattern) {
+		return nil, false
+	}
+
+	var params []string
+	for i, p := range rt.pattern {
+		if strings.HasPrefix(p, ":") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params = append(params, segments[i])
+			continue
+		}
+		if p != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// badRequest is an error caused by the request, its message is shown to the client as it is.
+type badRequest string
+
+func (e badRequest) Error() string { return string(e) }
+
+func badRequestf(format string, a ...interface{}) error {
+	return badRequest(fmt.Sprintf(format, a...))
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// statusOf maps the errors of the store to the HTTP status telling the client what went wrong.
+func statusOf(err error) int {
+	var bad badRequest
+	switch {
+	case errors.As(err, &bad), errors.Is(err, dynamodb.ErrNegativeStock), errors.Is(err, dynamodb.ErrTooManySearchTerms),
+		errors.Is(err, dynamodb.ErrInvalidPaginationKey), errors.Is(err, dynamodb.ErrInvalidIdempotencyKey):
+		return http.StatusBadRequest
+	case errors.Is(err, dynamodb.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dynamodb.ErrConflict),
+		errors.Is(err, dynamodb.ErrIdempotencyKeyReused),
+		errors.Is(err, dynamodb.ErrAlreadyReviewed),
+		errors.Is(err, dynamodb.ErrAlreadyModerated):
+		return http.StatusConflict
+	case errors.Is(err, dynamodb.ErrInvalidTransition),
+		errors.Is(err, dynamodb.ErrCouponNotApplicable),
+		errors.Is(err, dynamodb.ErrEmptyBasket),
+		errors.Is(err, dynamodb.ErrArchived),
+		errors.Is(err, dynamodb.ErrOptionInUse):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, dynamodb.ErrNoBlobStore):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := statusOf(err)
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("api: %v", err)
+		msg = "internal error" // Keeps the details of the store from the client.
+	}
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("api: writing response: %v", err)
+	}
+}
+
+// readJSON decodes the body of r into v, refusing fields v doesn't have.
+func readJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return badRequestf("Could not decode the request body: %v.", err)
+	}
+	return nil
+}
+
+// parseID parses the path parameter name as a SortableID.
+func parseID(name, value string) (dynamodb.SortableID, error) {
+	id, err := dynamodb.ParseSortableID(value)
+	if err != nil {
+		return dynamodb.SortableID{}, badRequestf("Expected %s to be a valid id.", name)
+	}
+	return id, nil
+}
+
+// writeOptions turns the Idempotency-Key header of r into the options of the write it asks for.
+func writeOptions(r *http.Request) ([]dynamodb.WriteOption, error) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return nil, nil
+	}
+	return []dynamodb.WriteOption{dynamodb.WithIdempotencyKey(key)}, nil
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Tinee/tewq/dynamodb"
+	"github.com/matryer/is"
+)
+
+// fakeStore satisfies Store, every method doing what its field says and failing with err when it's set.
+type fakeStore struct {
+	err      error
+	products map[dynamodb.SortableID]dynamodb.Product
+	basket   []dynamodb.BasketItem
+	category *dynamodb.GetProductsByCategoryInput
+	opts     []dynamodb.WriteOption // Of the last write.
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{products: map[dynamodb.SortableID]dynamodb.Product{}}
+}
+
+func (s *fakeStore) AddProduct(p dynamodb.Product, opts ...dynamodb.WriteOption) (dynamodb.Product, error) {
+	s.opts = opts
+	p.ID = dynamodb.NewSortableID()
+	s.products[p.ID] = p
+	return p, s.err
+}
+
+func (s *fakeStore) GetProduct(id dynamodb.SortableID) (dynamodb.Product, error) {
+	return s.products[id], s.err
+}
+
+func (s *fakeStore) DeleteProduct(id dynamodb.SortableID) error {
+	if _, ok := s.products[id]; !ok {
+		return dynamodb.ErrNotFound
+	}
+	delete(s.products, id)
+	return s.err
+}
+
+func (s *fakeStore) AddOptionToProduct(id dynamodb.SortableID, o dynamodb.Option) (dynamodb.Option, error) {
+	o.ID, o.ProductID = dynamodb.NewSortableID(), id
+	return o, s.err
+}
+
+func (s *fakeStore) GetOption(productID, optionID dynamodb.SortableID) (dynamodb.Option, error) {
+	if _, ok := s.products[productID]; !ok {
+		return dynamodb.Option{}, dynamodb.ErrNotFound
+	}
+	return dynamodb.Option{ID: optionID, ProductID: productID}, s.err
+}
+
+func (s *fakeStore) UpdateOption(productID, optionID dynamodb.SortableID, input dynamodb.UpdateOptionInput) (dynamodb.Option, error) {
+	o := dynamodb.Option{ID: optionID, ProductID: productID}
+	if input.Color != nil {
+		o.Color = *input.Color
+	}
+	if input.Stock != nil {
+		o.Stock = *input.Stock
+	}
+	return o, s.err
+}
+
+func (s *fakeStore) DeleteOption(productID, optionID dynamodb.SortableID) error {
+	return s.err
+}
+
+func (s *fakeStore) SetOptionStock(productID, optionID dynamodb.SortableID, stock int) (dynamodb.Option, error) {
+	return dynamodb.Option{ID: optionID, ProductID: productID, Stock: stock}, s.err
+}
+
+func (s *fakeStore) ArchiveProduct(id dynamodb.SortableID) (dynamodb.Product, error) {
+	p, ok := s.products[id]
+	if !ok {
+		return dynamodb.Product{}, dynamodb.ErrNotFound
+	}
+	p.Archived = true
+	s.products[id] = p
+	return p, s.err
+}
+
+func (s *fakeStore) RestoreProduct(id dynamodb.SortableID) (dynamodb.Product, error) {
+	p, ok := s.products[id]
+	if !ok {
+		return dynamodb.Product{}, dynamodb.ErrNotFound
+	}
+	p.Archived = false
+	s.products[id] = p
+	return p, s.err
+}
+
+func (s *fakeStore) ArchiveOption(productID, optionID dynamodb.SortableID) (dynamodb.Option, error) {
+	return dynamodb.Option{ID: optionID, ProductID: productID, Archived: true}, s.err
+}
+
+func (s *fakeStore) RestoreOption(productID, optionID dynamodb.SortableID) (dynamodb.Option, error) {
+	return dynamodb.Option{ID: optionID, ProductID: productID}, s.err
+}
+
+func (s *fakeStore) GetProductsByCategory(input *dynamodb.GetProductsByCategoryInput) ([]dynamodb.Product, dynamodb.PaginationKey, error) {
+	s.category = input
+	return nil, "next", s.err
+}
+
+func (s *fakeStore) SearchProducts(input *dynamodb.SearchProductsInput) ([]dynamodb.SearchResult, error) {
+	return nil, s.err
+}
+
+func (s *fakeStore) AddBasketItem(item dynamodb.BasketItem, opts ...dynamodb.WriteOption) error {
+	s.opts = opts
+	s.basket = append(s.basket, item)
+	return s.err
+}
+
+func (s *fakeStore) GetBasketItems(customerID dynamodb.SortableID) ([]dynamodb.BasketItem, error) {
+	return s.basket, s.err
+}
+
+func (s *fakeStore) GetBasketSummary(customerID dynamodb.SortableID) (dynamodb.BasketSummary, error) {
+	return dynamodb.BasketSummary{}, s.err
+}
+
+func (s *fakeStore) Checkout(customerID dynamodb.SortableID) (dynamodb.Order, error) {
+	if len(s.basket) == 0 {
+		return dynamodb.Order{}, dynamodb.ErrEmptyBasket
+	}
+	return dynamodb.Order{ID: dynamodb.NewSortableID(), CustomerID: customerID}, s.err
+}
+
+// serve sends a request to a Handler over store, decoding the response body into v when it's given.
+func serve(t *testing.T, store Store, method, target, body string, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	w := httptest.NewRecorder()
+	New(store).ServeHTTP(w, httptest.NewRequest(method, target, r))
+
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("decoding %q: %v", w.Body.String(), err)
+		}
+	}
+	return w
+}
+
+func TestRouting(t *testing.T) {
+	is := is.New(t)
+	store := newFakeStore()
+
+	w := serve(t, store, http.MethodGet, "/nothing/here", "", nil)
+	is.Equal(w.Code, http.StatusNotFound)
+
+	w = serve(t, store, http.MethodPatch, "/products", "", nil)
+	is.Equal(w.Code, http.StatusMethodNotAllowed)
+	is.Equal(w.Header().Get("Allow"), http.MethodPost)
+
+	w = serve(t, store, http.MethodGet, "/products/not-an-id", "", nil)
+	is.Equal(w.Code, http.StatusBadRequest)
+}
+
+func TestStatusOf(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(statusOf(badRequest("nope")), http.StatusBadRequest)
+	is.Equal(statusOf(dynamodb.ErrNegativeStock), http.StatusBadRequest)
+	is.Equal(statusOf(dynamodb.ErrTooManySearchTerms), http.StatusBadRequest)
+	is.Equal(statusOf(dynamodb.ErrInvalidPaginationKey), http.StatusBadRequest)
+	is.Equal(statusOf(dynamodb.ErrInvalidIdempotencyKey), http.StatusBadRequest)
+	is.Equal(statusOf(dynamodb.ErrNotFound), http.StatusNotFound)
+	is.Equal(statusOf(dynamodb.ErrConflict), http.StatusConflict)
+	is.Equal(statusOf(dynamodb.ErrEmptyBasket), http.StatusUnprocessableEntity)
+	is.Equal(statusOf(errors.New("boom")), http.StatusInternalServerError)
+}
+
+func TestInternalErrorsAreHidden(t *testing.T) {
+	is := is.New(t)
+	store := newFakeStore()
+	store.err = errors.New("table Tewq does not exist")
+
+	var res errorResponse
+	w := serve(t, store, http.MethodGet, "/baskets/"+dynamodb.NewSortableID().String(), "", &res)
+	is.Equal(w.Code, http.StatusInternalServerError)
+	is.Equal(res.Error, "internal error")
+}
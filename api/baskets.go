@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Tinee/tewq/dynamodb"
+)
+
+type addBasketItemRequest struct {
+	ProductID       dynamodb.SortableID `json:"productId"`
+	ProductOptionID dynamodb.SortableID `json:"productOptionId"`
+	Quantity        int                 `json:"quantity"`
+}
+
+func (h *Handler) addBasketItem(w http.ResponseWriter, r *http.Request, params []string) error {
+	customerID, err := parseID("customerId", params[0])
+	if err != nil {
+		return err
+	}
+
+	var req addBasketItemRequest
+	if err := readJSON(r, &req); err != nil {
+		return err
+	}
+	switch {
+	case req.ProductID == (dynamodb.SortableID{}):
+		return badRequest("Expected productId to have a value.")
+	case req.ProductOptionID == (dynamodb.SortableID{}):
+		return badRequest("Expected productOptionId to have a value.")
+	case req.Quantity < 0:
+		return badRequest("Expected quantity to not be negative.")
+	}
+
+	opts, err := writeOptions(r)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.AddBasketItem(dynamodb.BasketItem{
+		CustomerID:      customerID,
+		ProductID:       req.ProductID,
+		ProductOptionID: req.ProductOptionID,
+		Quantity:        req.Quantity,
+	}, opts...)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) getBasketItems(w http.ResponseWriter, r *http.Request, params []string) error {
+	customerID, err := parseID("customerId", params[0])
+	if err != nil {
+		return err
+	}
+
+	items, err := h.store.GetBasketItems(customerID)
+	if err != nil {
+		return err
+	}
+	if items == nil {
+		items = []dynamodb.BasketItem{}
+	}
+
+	writeJSON(w, http.StatusOK, items)
+	return nil
+}
+
+func (h *Handler) getBasketSummary(w http.ResponseWriter, r *http.Request, params []string) error {
+	customerID, err := parseID("customerId", params[0])
+	if err != nil {
+		return err
+	}
+
+	summary, err := h.store.GetBasketSummary(customerID)
+	if err != nil {
+		return err
+	}
+	if summary.Lines == nil {
+		summary.Lines = []dynamodb.BasketLine{}
+	}
+
+	writeJSON(w, http.StatusOK, summary)
+	return nil
+}
+
+func (h *Handler) checkout(w http.ResponseWriter, r *http.Request, params []string) error {
+	customerID, err := parseID("customerId", params[0])
+	if err != nil {
+		return err
+	}
+
+	order, err := h.store.Checkout(customerID)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusCreated, order)
+	return nil
+}
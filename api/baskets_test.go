@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Tinee/tewq/dynamodb"
+	"github.com/matryer/is"
+)
+
+func TestBasket(t *testing.T) {
+	is := is.New(t)
+	store := newFakeStore()
+	customerID := dynamodb.NewSortableID()
+	basket := "/baskets/" + customerID.String()
+
+	w := serve(t, store, http.MethodPost, basket+"/checkout", "", nil)
+	is.Equal(w.Code, http.StatusUnprocessableEntity) // Nothing in the basket.
+
+	body := `{"productId":"` + dynamodb.NewSortableID().String() + `","productOptionId":"` + dynamodb.NewSortableID().String() + `","quantity":2}`
+	w = serve(t, store, http.MethodPost, basket+"/items", body, nil)
+	is.Equal(w.Code, http.StatusNoContent)
+	is.Equal(store.basket[0].CustomerID, customerID)
+	is.Equal(store.basket[0].Quantity, 2)
+
+	var items []dynamodb.BasketItem
+	w = serve(t, store, http.MethodGet, basket+"/items", "", &items)
+	is.Equal(w.Code, http.StatusOK)
+	is.Equal(len(items), 1)
+
+	var order dynamodb.Order
+	w = serve(t, store, http.MethodPost, basket+"/checkout", "", &order)
+	is.Equal(w.Code, http.StatusCreated)
+	is.Equal(order.CustomerID, customerID)
+}
+
+func TestAddBasketItemValidation(t *testing.T) {
+	is := is.New(t)
+	basket := "/baskets/" + dynamodb.NewSortableID().String() + "/items"
+
+	w := serve(t, newFakeStore(), http.MethodPost, basket, `{"productId":"`+dynamodb.NewSortableID().String()+`"}`, nil)
+	is.Equal(w.Code, http.StatusBadRequest) // No option.
+
+	w = serve(t, newFakeStore(), http.MethodPost, basket, `{"productId":"nope"}`, nil)
+	is.Equal(w.Code, http.StatusBadRequest)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandleAPIGateway serves an API Gateway proxy event with the same routes ServeHTTP serves,
+// so the API runs on Lambda without anything changing about it.
+func (h *Handler) HandleAPIGateway(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	r, err := proxyRequest(ctx, event)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	w := &proxyResponseWriter{header: http.Header{}}
+	h.ServeHTTP(w, r)
+
+	return w.response(), nil
+}
+
+// proxyRequest turns an API Gateway proxy event into the http.Request it stands for.
+func proxyRequest(ctx context.Context, event events.APIGatewayProxyRequest) (*http.Request, error) {
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+
+	query := url.Values{}
+	for name, values := range event.MultiValueQueryStringParameters {
+		query[name] = values
+	}
+	for name, value := range event.QueryStringParameters {
+		if _, ok := query[name]; !ok {
+			query.Set(name, value)
+		}
+	}
+
+	u := url.URL{Path: event.Path, RawQuery: query.Encode()}
+	r, err := http.NewRequestWithContext(ctx, event.HTTPMethod, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range event.MultiValueHeaders {
+		for _, v := range values {
+			r.Header.Add(name, v)
+		}
+	}
+	for name, value := range event.Headers {
+		if r.Header.Get(name) == "" {
+			r.Header.Set(name, value)
+		}
+	}
+	r.RemoteAddr = event.RequestContext.Identity.SourceIP
+
+	return r, nil
+}
+
+// proxyResponseWriter collects what a handler writes, to hand it back to API Gateway in one go.
+type proxyResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *proxyResponseWriter) Header() http.Header { return w.header }
+
+func (w *proxyResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *proxyResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *proxyResponseWriter) response() events.APIGatewayProxyResponse {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	headers := make(map[string]string, len(w.header))
+	for name, values := range w.header {
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode:        status,
+		Headers:           headers,
+		MultiValueHeaders: w.header,
+		Body:              w.body.String(),
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/Tinee/tewq/dynamodb"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/matryer/is"
+)
+
+// loadEvent reads a recorded API Gateway proxy event from testdata.
+func loadEvent(t *testing.T, name string) events.APIGatewayProxyRequest {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event events.APIGatewayProxyRequest
+	if err := json.Unmarshal(b, &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func mustParseID(t *testing.T, s string) dynamodb.SortableID {
+	t.Helper()
+
+	id, err := dynamodb.ParseSortableID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestHandleAPIGatewayGetProduct(t *testing.T) {
+	is := is.New(t)
+	store := newFakeStore()
+	id := mustParseID(t, "3KtnyZSaBpM8c5m0MBX2WxYaamN")
+	store.products[id] = dynamodb.Product{ID: id, Name: "Driver", Category: "Clubs"}
+
+	res, err := New(store).HandleAPIGateway(context.Background(), loadEvent(t, "get_product.json"))
+	is.NoErr(err)
+	is.Equal(res.StatusCode, http.StatusOK)
+	is.Equal(res.Headers["Content-Type"], "application/json")
+
+	var p dynamodb.Product
+	is.NoErr(json.Unmarshal([]byte(res.Body), &p))
+	is.Equal(p.Name, "Driver")
+}
+
+func TestHandleAPIGatewayGetProductsByCategory(t *testing.T) {
+	is := is.New(t)
+	store := newFakeStore()
+
+	res, err := New(store).HandleAPIGateway(context.Background(), loadEvent(t, "get_products_by_category.json"))
+	is.NoErr(err)
+	is.Equal(res.StatusCode, http.StatusOK)
+	is.Equal(store.category.Category, "Clubs")
+	is.Equal(store.category.FromPrice, 100)
+	is.Equal(store.category.PaginationLimit, 10)
+}
+
+func TestHandleAPIGatewayAddBasketItem(t *testing.T) {
+	is := is.New(t)
+	store := newFakeStore()
+
+	res, err := New(store).HandleAPIGateway(context.Background(), loadEvent(t, "add_basket_item.json"))
+	is.NoErr(err)
+	is.Equal(res.StatusCode, http.StatusNoContent)
+	is.Equal(len(store.basket), 1) // The base64 encoded body got decoded.
+	is.Equal(store.basket[0].CustomerID, mustParseID(t, "3KtnyVeBepZs9zQLR2YFjzM08Kt"))
+	is.Equal(store.basket[0].Quantity, 2)
+}
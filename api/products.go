@@ -0,0 +1,348 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Tinee/tewq/dynamodb"
+)
+
+// maxPageSize is the most items a client can ask for in a single page.
+const maxPageSize = 100
+
+func (h *Handler) addProduct(w http.ResponseWriter, r *http.Request, _ []string) error {
+	var p dynamodb.Product
+	if err := readJSON(r, &p); err != nil {
+		return err
+	}
+
+	switch {
+	case p.Name == "":
+		return badRequest("Expected name to have a value.")
+	case p.Category == "":
+		return badRequest("Expected category to have a value.")
+	case p.Price < 0 || p.Sale < 0 || p.Weight < 0:
+		return badRequest("Expected price, sale and weight to not be negative.")
+	case len(p.Options) > 0:
+		return badRequest("Expected options to be added with POST /products/{id}/options.")
+	case p.RatingCount != 0 || p.RatingHistogram != (dynamodb.RatingHistogram{}) || p.AverageRating != 0:
+		return badRequest("Expected ratingCount, ratingHistogram and averageRating to be left to the reviews.")
+	case p.Version != 0 || p.UpdatedBy != "" || p.UpdatedDate != nil:
+		return badRequest("Expected version, updatedBy and updatedUtc to be left to the store.")
+	case p.Archived || p.ArchivedDate != nil:
+		return badRequest("Expected products to be archived with POST /products/{id}/archive.")
+	}
+
+	opts, err := writeOptions(r)
+	if err != nil {
+		return err
+	}
+
+	p, err = h.store.AddProduct(p, opts...)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusCreated, p)
+	return nil
+}
+
+func (h *Handler) getProduct(w http.ResponseWriter, r *http.Request, params []string) error {
+	id, err := parseID("id", params[0])
+	if err != nil {
+		return err
+	}
+
+	p, err := h.store.GetProduct(id)
+	if err != nil {
+		return err
+	}
+	if p.ID == (dynamodb.SortableID{}) {
+		return dynamodb.ErrNotFound // GetProduct doesn't tell by itself.
+	}
+
+	writeJSON(w, http.StatusOK, p)
+	return nil
+}
+
+func (h *Handler) deleteProduct(w http.ResponseWriter, r *http.Request, params []string) error {
+	id, err := parseID("id", params[0])
+	if err != nil {
+		return err
+	}
+
+	if err := h.store.DeleteProduct(id); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) archiveProduct(w http.ResponseWriter, r *http.Request, params []string) error {
+	return h.setProductArchived(w, params, h.store.ArchiveProduct)
+}
+
+func (h *Handler) restoreProduct(w http.ResponseWriter, r *http.Request, params []string) error {
+	return h.setProductArchived(w, params, h.store.RestoreProduct)
+}
+
+func (h *Handler) setProductArchived(w http.ResponseWriter, params []string, set func(dynamodb.SortableID) (dynamodb.Product, error)) error {
+	id, err := parseID("id", params[0])
+	if err != nil {
+		return err
+	}
+
+	p, err := set(id)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, p)
+	return nil
+}
+
+func (h *Handler) addOption(w http.ResponseWriter, r *http.Request, params []string) error {
+	id, err := parseID("id", params[0])
+	if err != nil {
+		return err
+	}
+
+	var o dynamodb.Option
+	if err := readJSON(r, &o); err != nil {
+		return err
+	}
+	if o.Stock < 0 {
+		return badRequest("Expected stock to not be negative.")
+	}
+
+	o, err = h.store.AddOptionToProduct(id, o)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusCreated, o)
+	return nil
+}
+
+func (h *Handler) getOption(w http.ResponseWriter, r *http.Request, params []string) error {
+	productID, optionID, err := parseOptionIDs(params)
+	if err != nil {
+		return err
+	}
+
+	o, err := h.store.GetOption(productID, optionID)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, o)
+	return nil
+}
+
+type updateOptionRequest struct {
+	Size           *string  `json:"size"`
+	Socket         *string  `json:"socket"`
+	Color          *string  `json:"color"`
+	ShaftStiffness *float64 `json:"shaftStiffness"`
+	Stock          *int     `json:"stock"`
+}
+
+func (h *Handler) updateOption(w http.ResponseWriter, r *http.Request, params []string) error {
+	productID, optionID, err := parseOptionIDs(params)
+	if err != nil {
+		return err
+	}
+
+	var req updateOptionRequest
+	if err := readJSON(r, &req); err != nil {
+		return err
+	}
+	switch {
+	case req.Size == nil && req.Socket == nil && req.Color == nil && req.ShaftStiffness == nil && req.Stock == nil:
+		return badRequest("Expected at least one of size, socket, color, shaftStiffness and stock.")
+	case req.Stock != nil && *req.Stock < 0:
+		return badRequest("Expected stock to not be negative.")
+	}
+
+	o, err := h.store.UpdateOption(productID, optionID, dynamodb.UpdateOptionInput{
+		Size:           req.Size,
+		Socket:         req.Socket,
+		Color:          req.Color,
+		ShaftStiffness: req.ShaftStiffness,
+		Stock:          req.Stock,
+	})
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, o)
+	return nil
+}
+
+func (h *Handler) deleteOption(w http.ResponseWriter, r *http.Request, params []string) error {
+	productID, optionID, err := parseOptionIDs(params)
+	if err != nil {
+		return err
+	}
+
+	if err := h.store.DeleteOption(productID, optionID); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// parseOptionIDs parses the product and option ids of the /products/{id}/options/{optionId} routes.
+func parseOptionIDs(params []string) (dynamodb.SortableID, dynamodb.SortableID, error) {
+	productID, err := parseID("id", params[0])
+	if err != nil {
+		return dynamodb.SortableID{}, dynamodb.SortableID{}, err
+	}
+	optionID, err := parseID("optionId", params[1])
+	if err != nil {
+		return dynamodb.SortableID{}, dynamodb.SortableID{}, err
+	}
+
+	return productID, optionID, nil
+}
+
+type setStockRequest struct {
+	Stock *int `json:"stock"`
+}
+
+func (h *Handler) setOptionStock(w http.ResponseWriter, r *http.Request, params []string) error {
+	productID, optionID, err := parseOptionIDs(params)
+	if err != nil {
+		return err
+	}
+
+	var req setStockRequest
+	if err := readJSON(r, &req); err != nil {
+		return err
+	}
+	if req.Stock == nil || *req.Stock < 0 {
+		return badRequest("Expected stock to have a value that isn't negative.")
+	}
+
+	o, err := h.store.SetOptionStock(productID, optionID, *req.Stock)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, o)
+	return nil
+}
+
+type productsPage struct {
+	Products []dynamodb.Product `json:"products"`
+	Cursor   string             `json:"cursor,omitempty"` // Pass it back as ?cursor= for the next page.
+}
+
+func (h *Handler) getProductsByCategory(w http.ResponseWriter, r *http.Request, params []string) error {
+	q := r.URL.Query()
+
+	input := &dynamodb.GetProductsByCategoryInput{
+		Category:    params[0],
+		PreviousKey: dynamodb.PaginationKey(q.Get("cursor")),
+	}
+	var err error
+	if input.FromPrice, err = queryInt(q.Get("from"), "from"); err != nil {
+		return err
+	}
+	if input.ToPrice, err = queryInt(q.Get("to"), "to"); err != nil {
+		return err
+	}
+	if input.PaginationLimit, err = queryLimit(q.Get("limit")); err != nil {
+		return err
+	}
+	if input.ToPrice != 0 && input.ToPrice < input.FromPrice {
+		return badRequest("Expected to to not be smaller than from.")
+	}
+
+	products, next, err := h.store.GetProductsByCategory(input)
+	if err != nil {
+		return err
+	}
+	if products == nil {
+		products = []dynamodb.Product{}
+	}
+
+	writeJSON(w, http.StatusOK, productsPage{Products: products, Cursor: string(next)})
+	return nil
+}
+
+func (h *Handler) searchProducts(w http.ResponseWriter, r *http.Request, _ []string) error {
+	q := r.URL.Query()
+
+	input := &dynamodb.SearchProductsInput{
+		Query:  q.Get("q"),
+		Prefix: q.Get("prefix") == "true",
+	}
+	if input.Query == "" {
+		return badRequest("Expected q to have a value.")
+	}
+	var err error
+	if input.Limit, err = queryLimit(q.Get("limit")); err != nil {
+		return err
+	}
+
+	results, err := h.store.SearchProducts(input)
+	if err != nil {
+		return err
+	}
+	if results == nil {
+		results = []dynamodb.SearchResult{}
+	}
+
+	writeJSON(w, http.StatusOK, results)
+	return nil
+}
+
+// queryInt parses an optional, non negative, integer query parameter.
+func queryInt(value, name string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, badRequestf("Expected %s to be a number that isn't negative.", name)
+	}
+	return n, nil
+}
+
+// queryLimit parses the optional page size, 0 leaving it to the store.
+func queryLimit(value string) (int, error) {
+	n, err := queryInt(value, "limit")
+	if err != nil {
+		return 0, err
+	}
+	if n > maxPageSize {
+		return 0, badRequestf("Expected limit to be at most %d.", maxPageSize)
+	}
+	return n, nil
+}
+
+func (h *Handler) archiveOption(w http.ResponseWriter, r *http.Request, params []string) error {
+	return h.setOptionArchived(w, params, h.store.ArchiveOption)
+}
+
+func (h *Handler) restoreOption(w http.ResponseWriter, r *http.Request, params []string) error {
+	return h.setOptionArchived(w, params, h.store.RestoreOption)
+}
+
+func (h *Handler) setOptionArchived(w http.ResponseWriter, params []string, set func(productID, optionID dynamodb.SortableID) (dynamodb.Option, error)) error {
+	productID, optionID, err := parseOptionIDs(params)
+	if err != nil {
+		return err
+	}
+
+	o, err := set(productID, optionID)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, o)
+	return nil
+}
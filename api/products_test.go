@@ -0,0 +1,158 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Tinee/tewq/dynamodb"
+	"github.com/matryer/is"
+)
+
+func TestAddAndGetProduct(t *testing.T) {
+	is := is.New(t)
+	store := newFakeStore()
+
+	var added dynamodb.Product
+	w := serve(t, store, http.MethodPost, "/products", `{"name":"Driver","category":"Clubs","price":300}`, &added)
+	is.Equal(w.Code, http.StatusCreated)
+	is.Equal(added.Name, "Driver")
+
+	var fetched dynamodb.Product
+	w = serve(t, store, http.MethodGet, "/products/"+added.ID.String(), "", &fetched)
+	is.Equal(w.Code, http.StatusOK)
+	is.Equal(fetched.ID, added.ID)
+
+	w = serve(t, store, http.MethodGet, "/products/"+dynamodb.NewSortableID().String(), "", nil)
+	is.Equal(w.Code, http.StatusNotFound)
+}
+
+func TestAddProductIdempotencyKey(t *testing.T) {
+	is := is.New(t)
+	store := newFakeStore()
+	body := `{"name":"Driver","category":"Clubs"}`
+
+	w := serve(t, store, http.MethodPost, "/products", body, nil)
+	is.Equal(w.Code, http.StatusCreated)
+	is.Equal(len(store.opts), 0) // No header, no key.
+
+	r := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+	r.Header.Set("Idempotency-Key", "3f1c9a7e")
+	w = httptest.NewRecorder()
+	New(store).ServeHTTP(w, r)
+	is.Equal(w.Code, http.StatusCreated)
+	is.Equal(len(store.opts), 1)
+
+	store.err = dynamodb.ErrInvalidIdempotencyKey // The store refuses keys that are too long.
+	r = httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+	r.Header.Set("Idempotency-Key", strings.Repeat("k", 256))
+	w = httptest.NewRecorder()
+	New(store).ServeHTTP(w, r)
+	is.Equal(w.Code, http.StatusBadRequest)
+}
+
+func TestArchiveProduct(t *testing.T) {
+	is := is.New(t)
+	store := newFakeStore()
+
+	var added, archived, restored dynamodb.Product
+	serve(t, store, http.MethodPost, "/products", `{"name":"Gloves","category":"Clothes"}`, &added)
+
+	w := serve(t, store, http.MethodPost, "/products/"+added.ID.String()+"/archive", "", &archived)
+	is.Equal(w.Code, http.StatusOK)
+	is.True(archived.Archived)
+
+	w = serve(t, store, http.MethodPost, "/products/"+added.ID.String()+"/restore", "", &restored)
+	is.Equal(w.Code, http.StatusOK)
+	is.True(!restored.Archived)
+
+	w = serve(t, store, http.MethodPost, "/products/"+dynamodb.NewSortableID().String()+"/archive", "", nil)
+	is.Equal(w.Code, http.StatusNotFound)
+
+	var option dynamodb.Option
+	w = serve(t, store, http.MethodPost, "/products/"+added.ID.String()+"/options/"+dynamodb.NewSortableID().String()+"/archive", "", &option)
+	is.Equal(w.Code, http.StatusOK)
+	is.True(option.Archived)
+}
+
+func TestOptions(t *testing.T) {
+	is := is.New(t)
+	store := newFakeStore()
+
+	var p dynamodb.Product
+	serve(t, store, http.MethodPost, "/products", `{"name":"Gloves","category":"Clothes"}`, &p)
+	path := "/products/" + p.ID.String() + "/options/" + dynamodb.NewSortableID().String()
+
+	w := serve(t, store, http.MethodGet, path, "", nil)
+	is.Equal(w.Code, http.StatusOK)
+	w = serve(t, store, http.MethodGet, "/products/"+dynamodb.NewSortableID().String()+"/options/"+dynamodb.NewSortableID().String(), "", nil)
+	is.Equal(w.Code, http.StatusNotFound)
+
+	var updated dynamodb.Option
+	w = serve(t, store, http.MethodPatch, path, `{"color":"Black","stock":4}`, &updated)
+	is.Equal(w.Code, http.StatusOK)
+	is.Equal(updated.Color, "Black")
+	is.Equal(updated.Stock, 4)
+
+	w = serve(t, store, http.MethodPatch, path, `{}`, nil)
+	is.Equal(w.Code, http.StatusBadRequest)
+	w = serve(t, store, http.MethodPatch, path, `{"stock":-1}`, nil)
+	is.Equal(w.Code, http.StatusBadRequest)
+
+	w = serve(t, store, http.MethodDelete, path, "", nil)
+	is.Equal(w.Code, http.StatusNoContent)
+
+	store.err = dynamodb.ErrOptionInUse
+	w = serve(t, store, http.MethodDelete, path, "", nil)
+	is.Equal(w.Code, http.StatusUnprocessableEntity)
+}
+
+func TestAddProductValidation(t *testing.T) {
+	is := is.New(t)
+
+	for _, body := range []string{
+		`{"category":"Clubs"}`,
+		`{"name":"Driver"}`,
+		`{"name":"Driver","category":"Clubs","price":-1}`,
+		`{"name":"Driver","category":"Clubs","colour":"red"}`,
+		`{"name":"Driver","category":"Clubs","ratingCount":1000}`,
+		`{"name":"Driver","category":"Clubs","version":99}`,
+		`{"name":"Driver","category":"Clubs","archived":true}`,
+		`not json`,
+	} {
+		w := serve(t, newFakeStore(), http.MethodPost, "/products", body, nil)
+		is.Equal(w.Code, http.StatusBadRequest) // body
+	}
+}
+
+func TestSetOptionStock(t *testing.T) {
+	is := is.New(t)
+	target := "/products/" + dynamodb.NewSortableID().String() + "/options/" + dynamodb.NewSortableID().String() + "/stock"
+
+	var o dynamodb.Option
+	w := serve(t, newFakeStore(), http.MethodPut, target, `{"stock":7}`, &o)
+	is.Equal(w.Code, http.StatusOK)
+	is.Equal(o.Stock, 7)
+
+	w = serve(t, newFakeStore(), http.MethodPut, target, `{}`, nil)
+	is.Equal(w.Code, http.StatusBadRequest) // Stock is required, even when it's 0.
+}
+
+func TestGetProductsByCategory(t *testing.T) {
+	is := is.New(t)
+	store := newFakeStore()
+
+	var page productsPage
+	w := serve(t, store, http.MethodGet, "/categories/Clubs/products?from=100&to=500&limit=10&cursor=abc", "", &page)
+	is.Equal(w.Code, http.StatusOK)
+	is.Equal(page.Cursor, "next")
+	is.Equal(store.category.Category, "Clubs")
+	is.Equal(store.category.FromPrice, 100)
+	is.Equal(store.category.ToPrice, 500)
+	is.Equal(store.category.PaginationLimit, 10)
+	is.Equal(string(store.category.PreviousKey), "abc")
+
+	w = serve(t, store, http.MethodGet, "/categories/Clubs/products?limit=1000", "", nil)
+	is.Equal(w.Code, http.StatusBadRequest)
+}
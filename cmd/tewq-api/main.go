@@ -0,0 +1,36 @@
+// Command tewq-api serves the store as a JSON REST API.
+//
+//	tewq-api -table Tewq -addr :8080
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/Tinee/tewq/api"
+	"github.com/Tinee/tewq/dynamodb"
+)
+
+func main() {
+	endpoint := flag.String("endpoint", "http://localhost:8000", "DynamoDB endpoint to use")
+	table := flag.String("table", "", "name of the table (required)")
+	addr := flag.String("addr", ":8080", "address to listen on")
+	flag.Parse()
+
+	if *table == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := dynamodb.New(*endpoint, *table)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, api.New(db)))
+}
@@ -0,0 +1,79 @@
+// Command tewq-import seeds the catalogue with products and options from a CSV or JSON Lines file.
+//
+//	tewq-import -table Tewq products.csv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Tinee/tewq/dynamodb"
+)
+
+func main() {
+	endpoint := flag.String("endpoint", "http://localhost:8000", "DynamoDB endpoint to import into")
+	table := flag.String("table", "", "name of the table to import into (required)")
+	format := flag.String("format", "", "csv or jsonl, guessed from the file extension when left out")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] FILE\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *table == "" || flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*endpoint, *table, *format, flag.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(endpoint, table, format, path string) error {
+	if format == "" {
+		format = formatFromExtension(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	db, err := dynamodb.New(endpoint, table)
+	if err != nil {
+		return err
+	}
+
+	report, err := db.ImportProducts(f, dynamodb.ImportFormat(format))
+	if err != nil {
+		return err
+	}
+
+	for _, row := range report.Rows {
+		if row.Err != nil {
+			fmt.Fprintf(os.Stderr, "row %d: %v\n", row.Row, row.Err)
+		}
+	}
+	fmt.Printf("imported %d rows, %d failed\n", report.Succeeded, report.Failed)
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d rows failed to import", report.Failed)
+	}
+
+	return nil
+}
+
+func formatFromExtension(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return string(dynamodb.ImportJSONL)
+	default:
+		return string(dynamodb.ImportCSV)
+	}
+}
@@ -0,0 +1,31 @@
+// Command tewq-lambda serves the API on AWS Lambda, behind an API Gateway proxy integration.
+//
+// It's configured through the environment of the function:
+//
+//	TEWQ_TABLE              name of the table (required)
+//	TEWQ_DYNAMODB_ENDPOINT  DynamoDB endpoint to use, the regional endpoint when it's empty
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/Tinee/tewq/api"
+	"github.com/Tinee/tewq/dynamodb"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func main() {
+	table := os.Getenv("TEWQ_TABLE")
+	if table == "" {
+		log.Fatal("TEWQ_TABLE is not set")
+	}
+
+	// Created once per container, every invocation it serves reuses the client and its connections.
+	db, err := dynamodb.New(os.Getenv("TEWQ_DYNAMODB_ENDPOINT"), table)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	lambda.Start(api.New(db).HandleAPIGateway)
+}
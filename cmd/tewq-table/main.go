@@ -0,0 +1,92 @@
+// Command tewq-table snapshots the single table to a JSON Lines file, and restores it from one.
+//
+//	tewq-table -table Tewq export > snapshot.jsonl
+//	tewq-table -table Tewq -endpoint https://dynamodb.eu-west-1.amazonaws.com import snapshot.jsonl
+//
+// The create command creates the table with all its indexes, handy before restoring into DynamoDB Local.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Tinee/tewq/dynamodb"
+)
+
+func main() {
+	endpoint := flag.String("endpoint", "http://localhost:8000", "DynamoDB endpoint to use")
+	table := flag.String("table", "", "name of the table (required)")
+	segments := flag.Int("segments", 4, "number of parallel scans when exporting")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] create | export [FILE] | import [FILE]\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "FILE defaults to stdout when exporting and stdin when importing.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *table == "" || flag.NArg() < 1 || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := dynamodb.New(*endpoint, *table)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	switch flag.Arg(0) {
+	case "create":
+		err = db.CreateTable()
+	case "export":
+		err = export(db, flag.Arg(1), *segments)
+	case "import":
+		err = restore(db, flag.Arg(1))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func export(db *dynamodb.DynamoDB, path string, segments int) error {
+	var w io.Writer = os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	n, err := db.ExportTable(w, segments)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d items\n", n)
+
+	return nil
+}
+
+func restore(db *dynamodb.DynamoDB, path string) error {
+	var r io.Reader = os.Stdin
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	n, err := db.ImportTable(r)
+	fmt.Fprintf(os.Stderr, "imported %d items\n", n)
+
+	return err
+}
@@ -0,0 +1,163 @@
+package dynamodb
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ArchiveProduct retires a product without deleting it. It's taken out of the category listings and the search,
+// and can't be checked out anymore, while the product itself, its options and its reviews are kept for RestoreProduct.
+// Its search tokens are deleted in the same transaction, so it's never archived while still found by the search.
+// Archiving a product that's archived already does nothing. The returned product doesn't include its options.
+func (db *DynamoDB) ArchiveProduct(id SortableID) (Product, error) {
+	p, err := db.getCurrentProduct(id)
+	if err != nil || p.Archived {
+		return p, err
+	}
+
+	now := db.now()
+	archived, err := dynamodbattribute.Marshal(now)
+	if err != nil {
+		return Product{}, err
+	}
+
+	// The tokens are the ones of the product as it was read, so it has to be unchanged.
+	names := map[string]*string{
+		"#Archived":    aws.String("Archived"),
+		"#ArchivedUtc": aws.String("ArchivedUtc"),
+		"#GSI1PK":      aws.String("GSI1PK"),
+		"#GSI1SK":      aws.String("GSI1SK"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":true":     {BOOL: aws.Bool(true)},
+		":archived": archived,
+	}
+	unchanged := unchangedCondition(p, names, values) + " And attribute_not_exists(#Archived)"
+
+	writes := []*dynamodb.TransactWriteItem{
+		{
+			Update: &dynamodb.Update{
+				TableName:                 aws.String(db.tableName),
+				Key:                       productKey(id),
+				ConditionExpression:       aws.String(unchanged),
+				UpdateExpression:          aws.String("SET #Archived = :true, #ArchivedUtc = :archived REMOVE #GSI1PK, #GSI1SK"),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		},
+	}
+	writes = append(writes, db.unindexWrites(p)...)
+
+	_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: writes})
+	if conditionFailedAt(err, 0) {
+		return Product{}, ErrConflict
+	}
+	if err != nil {
+		return Product{}, err
+	}
+
+	p.Archived = true
+	p.ArchivedDate = &now
+	return p, nil
+}
+
+// RestoreProduct brings an archived product back into the category listings and the search.
+// Its search tokens are written in the same transaction as the product.
+// Restoring a product that isn't archived does nothing. The returned product doesn't include its options.
+func (db *DynamoDB) RestoreProduct(id SortableID) (Product, error) {
+	p, err := db.getCurrentProduct(id)
+	if err != nil || !p.Archived {
+		return p, err
+	}
+
+	// The category index and the tokens are the ones of the product as it was read, so it has to be unchanged.
+	names := map[string]*string{
+		"#Archived":    aws.String("Archived"),
+		"#ArchivedUtc": aws.String("ArchivedUtc"),
+		"#GSI1PK":      aws.String("GSI1PK"),
+		"#GSI1SK":      aws.String("GSI1SK"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":true":   {BOOL: aws.Bool(true)},
+		":gsi1pk": {S: aws.String(categoryPK(p.Category))},
+		":gsi1sk": {S: aws.String(zerosPadding(p.Price))},
+	}
+	unchanged := unchangedCondition(p, names, values) + " And #Archived = :true"
+
+	writes := []*dynamodb.TransactWriteItem{
+		{
+			Update: &dynamodb.Update{
+				TableName:                 aws.String(db.tableName),
+				Key:                       productKey(id),
+				ConditionExpression:       aws.String(unchanged),
+				UpdateExpression:          aws.String("SET #GSI1PK = :gsi1pk, #GSI1SK = :gsi1sk REMOVE #Archived, #ArchivedUtc"),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		},
+	}
+	writes = append(writes, db.indexWrites(p)...)
+
+	_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: writes})
+	if conditionFailedAt(err, 0) {
+		return Product{}, ErrConflict
+	}
+	if err != nil {
+		return Product{}, err
+	}
+
+	p.Archived = false
+	p.ArchivedDate = nil
+	return p, nil
+}
+
+// ArchiveOption retires a single option of a product, like a colour that's out of season.
+// It stays with its product but can't be added to baskets or checked out, and is left out of the low stock listing.
+func (db *DynamoDB) ArchiveOption(productID, optionID SortableID) (Option, error) {
+	archived, err := dynamodbattribute.Marshal(db.now())
+	if err != nil {
+		return Option{}, err
+	}
+
+	return db.updateOptionArchived(productID, optionID,
+		"SET #Archived = :true, #ArchivedUtc = :archived",
+		map[string]*dynamodb.AttributeValue{
+			":true":     {BOOL: aws.Bool(true)},
+			":archived": archived,
+		})
+}
+
+// RestoreOption makes an archived option available again.
+func (db *DynamoDB) RestoreOption(productID, optionID SortableID) (Option, error) {
+	return db.updateOptionArchived(productID, optionID, "REMOVE #Archived, #ArchivedUtc", nil)
+}
+
+func (db *DynamoDB) updateOptionArchived(productID, optionID SortableID, update string, values map[string]*dynamodb.AttributeValue) (Option, error) {
+	res, err := db.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(db.tableName),
+		Key:                 optionKey(productID, optionID),
+		ConditionExpression: aws.String("attribute_exists(#PK)"),
+		UpdateExpression:    aws.String(update),
+		ExpressionAttributeNames: map[string]*string{
+			"#PK":          aws.String("PK"),
+			"#Archived":    aws.String("Archived"),
+			"#ArchivedUtc": aws.String("ArchivedUtc"),
+		},
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if isConditionFailed(err) {
+		return Option{}, ErrNotFound
+	}
+	if err != nil {
+		return Option{}, err
+	}
+
+	var o Option
+	if err := dynamodbattribute.UnmarshalMap(res.Attributes, &o); err != nil {
+		return Option{}, err
+	}
+
+	return o, nil
+}
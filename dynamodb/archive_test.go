@@ -0,0 +1,104 @@
+package dynamodb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestArchiveAndRestoreProduct(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Winter Gloves", Category: "Clothes", Price: 25})
+	is.NoErr(err)
+	_, err = tdb.AddOptionToProduct(p.ID, Option{Size: "M", Stock: 10})
+	is.NoErr(err)
+
+	archived, err := tdb.ArchiveProduct(p.ID)
+	is.NoErr(err)
+	is.True(archived.Archived)
+	is.True(archived.ArchivedDate != nil)
+
+	// Archiving twice leaves it archived.
+	archived, err = tdb.ArchiveProduct(p.ID)
+	is.NoErr(err)
+	is.True(archived.Archived)
+
+	fetched, err := tdb.GetProduct(p.ID)
+	is.NoErr(err)
+	is.True(fetched.Archived)
+	is.Equal(len(fetched.Options), 1) // The options are kept.
+
+	listed, _, err := tdb.GetProductsByCategory(&GetProductsByCategoryInput{Category: "Clothes"})
+	is.NoErr(err)
+	is.Equal(len(listed), 0)
+
+	found, err := tdb.SearchProducts(&SearchProductsInput{Query: "gloves"})
+	is.NoErr(err)
+	is.Equal(len(found), 0)
+
+	restored, err := tdb.RestoreProduct(p.ID)
+	is.NoErr(err)
+	is.True(!restored.Archived)
+
+	listed, _, err = tdb.GetProductsByCategory(&GetProductsByCategoryInput{Category: "Clothes"})
+	is.NoErr(err)
+	is.Equal(len(listed), 1)
+	is.True(listed[0].ArchivedDate == nil)
+
+	found, err = tdb.SearchProducts(&SearchProductsInput{Query: "gloves"})
+	is.NoErr(err)
+	is.Equal(len(found), 1)
+
+	_, err = tdb.ArchiveProduct(NewSortableID())
+	is.Equal(err, ErrNotFound)
+	_, err = tdb.RestoreProduct(NewSortableID())
+	is.Equal(err, ErrNotFound)
+}
+
+func TestArchiveOption(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB(WithLowStockThreshold(5))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Rain Jacket", Category: "Clothes", Price: 80})
+	is.NoErr(err)
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Yellow", Stock: 1})
+	is.NoErr(err)
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: p.ID, ProductOptionID: o.ID}))
+
+	archived, err := tdb.ArchiveOption(p.ID, o.ID)
+	is.NoErr(err)
+	is.True(archived.Archived)
+
+	low, _, err := tdb.ListLowStockOptions(&ListLowStockOptionsInput{})
+	is.NoErr(err)
+	is.Equal(len(low), 0)
+
+	err = tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: p.ID, ProductOptionID: o.ID})
+	is.Equal(err, ErrArchived)
+	_, err = tdb.Checkout(customerID) // Added before it was archived.
+	is.True(errors.Is(err, ErrArchived))
+
+	restored, err := tdb.RestoreOption(p.ID, o.ID)
+	is.NoErr(err)
+	is.True(!restored.Archived)
+
+	low, _, err = tdb.ListLowStockOptions(&ListLowStockOptionsInput{})
+	is.NoErr(err)
+	is.Equal(len(low), 1)
+
+	_, err = tdb.Checkout(customerID)
+	is.NoErr(err)
+
+	_, err = tdb.ArchiveOption(p.ID, NewSortableID())
+	is.Equal(err, ErrNotFound)
+}
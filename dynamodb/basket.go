@@ -1,7 +1,10 @@
 package dynamodb
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -15,88 +18,550 @@ type Basket struct {
 
 // BasketItem contains the pointers to which customer
 // wants which product within the basket.
+// Shoppers who haven't logged in have a guest basket, the items of which have a SessionID instead of a CustomerID.
+// A Quantity of 0 counts as 1.
 type BasketItem struct {
+	ID              SortableID `json:"id" dynamodbav:"Id,omitempty"`
 	CustomerID      SortableID `json:"customerId" dynamodbav:"CustomerId"`
+	SessionID       string     `json:"sessionId,omitempty" dynamodbav:"SessionId,omitempty"`
 	ProductID       SortableID `json:"productId" dynamodbav:"ProductId"`
 	ProductOptionID SortableID `json:"productOptionId" dynamodbav:"ProductOptionId"`
+	Quantity        int        `json:"quantity" dynamodbav:"Quantity,omitempty"`
+	// ExpiresAt is when DynamoDB deletes the item, in seconds since the epoch.
+	// Every change to the basket pushes it back for all its items.
+	ExpiresAt int64 `json:"expiresAt" dynamodbav:"ExpiresAt,omitempty"`
 }
 
 // AddBasketItem adds an BasketItem
-func (db *DynamoDB) AddBasketItem(item BasketItem) error {
-	pk := fmt.Sprintf("BASKET#%s", item.CustomerID)
-	sort := fmt.Sprintf("PRODUCT#%s", NewSortableID())
+// With WithIdempotencyKey a retried call doesn't add the item a second time.
+// Every item is of an option, one that doesn't exist fails with ErrNotFound, and an archived one with ErrArchived.
+func (db *DynamoDB) AddBasketItem(item BasketItem, opts ...WriteOption) error {
+	o, err := newWriteOptions(opts)
+	if err != nil {
+		return err
+	}
+	if (item.CustomerID == SortableID{}) == (item.SessionID == "") {
+		return errors.New("Expected either CustomerID or SessionID to have a value.")
+	}
+	if item.ProductOptionID == (SortableID{}) {
+		return errors.New("Expected ProductOptionID to have a value.")
+	}
+	if item.Quantity < 0 {
+		return errors.New("Expected Quantity to not be negative.")
+	}
+	if item.Quantity == 0 {
+		item.Quantity = 1
+	}
+	request := item
+	item.ID = db.newID()
+	item.ExpiresAt = db.basketExpiry(db.now())
 
-	i, err := dynamodbattribute.MarshalMap(&item)
+	i, err := basketItem(item)
 	if err != nil {
 		return err
 	}
-	i["Type"] = &dynamodb.AttributeValue{S: aws.String("BasketItem")}
-	i["PK"] = &dynamodb.AttributeValue{S: aws.String(pk)}
-	i["SK"] = &dynamodb.AttributeValue{S: aws.String(sort)}
 
-	_, err = db.db.PutItem(&dynamodb.PutItemInput{
-		TableName: aws.String(db.tableName),
-		Item:      i,
-	})
+	// The option counts the basket items holding it, see DeleteOption.
+	hold := db.holdOptionItem(item.ProductID, item.ProductOptionID, item.ExpiresAt)
 
-	return err
+	replayed, err := db.putOnce(o, "AddBasketItem", request, i, &item, hold)
+	if conditionFailedAt(err, 0) {
+		return db.optionUnavailable(item.ProductID, item.ProductOptionID)
+	}
+	if err != nil || replayed {
+		return err
+	}
+
+	return db.touchBasket(item.basketPK(), item.ExpiresAt)
 }
 
-func (db *DynamoDB) GetBasketProducts(customerID SortableID) ([]Product, error) {
-	pk := fmt.Sprintf("BASKET#%s", customerID)
+// basketExpiry is when a basket touched at now expires, 0 meaning never.
+func (db *DynamoDB) basketExpiry(now time.Time) int64 {
+	if db.basketTTL <= 0 {
+		return 0
+	}
+	return now.Add(db.basketTTL).Unix()
+}
 
-	res, err := db.db.Query(&dynamodb.QueryInput{
-		TableName:              aws.String(db.tableName),
-		KeyConditionExpression: aws.String("#PK = :pk"),
-		ExpressionAttributeNames: map[string]*string{
-			"#PK": aws.String("PK"),
-		},
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":pk": {
-				S: aws.String(pk),
-			},
-		},
-	})
+// isExpired tells if an item with the expiry expiresAt is gone as far as the basket is concerned.
+// DynamoDB deletes expired items lazily, up to days later, so they can still be read until then.
+func isExpired(expiresAt int64, now time.Time) bool {
+	return expiresAt > 0 && expiresAt <= now.Unix()
+}
+
+// touchBasket pushes the expiry of everything in the basket partition pk back to expiresAt.
+// Items that expired already are left to DynamoDB, they don't come back by touching the basket.
+// The items are updated in transactions of at most transactWriteLimit items, instead of one call per item,
+// together with how long the options they hold are held for.
+func (db *DynamoDB) touchBasket(pk string, expiresAt int64) error {
+	items, err := db.queryPartition(pk)
+	if err != nil {
+		return err
+	}
+	var read []BasketItem // The options every item holds, the basket coupon holding none.
+	if err := dynamodbattribute.UnmarshalListOfMaps(items, &read); err != nil {
+		return err
+	}
+
+	// Expired items would only fail the condition below, so they're left out up front.
+	now := db.now()
+	var live []map[string]*dynamodb.AttributeValue
+	var held []BasketItem
+	for i, item := range read {
+		if isExpired(item.ExpiresAt, now) {
+			continue
+		}
+		live = append(live, items[i])
+		held = append(held, item)
+	}
+	items = live
+
+	update := "SET #ExpiresAt = :expiresAt"
+	values := map[string]*dynamodb.AttributeValue{
+		":now":       {N: aws.String(fmt.Sprint(now.Unix()))},
+		":expiresAt": {N: aws.String(fmt.Sprint(expiresAt))},
+	}
+	if expiresAt == 0 {
+		update = "REMOVE #ExpiresAt"
+		delete(values, ":expiresAt")
+	}
+
+	// Every item can hold another option, so a transaction touches half a transactWriteLimit of items.
+	chunk := transactWriteLimit / 2
+	for start := 0; start < len(items); start += chunk {
+		end := start + chunk
+		if end > len(items) {
+			end = len(items)
+		}
+
+		pending := items[start:end]
+		holding := held[start:end]
+		gone := map[SortableID]bool{} // Options deleted since the items were read can't be held.
+		for len(pending) > 0 {
+			writes := make([]*dynamodb.TransactWriteItem, 0, 2*len(pending))
+			for _, item := range pending {
+				writes = append(writes, &dynamodb.TransactWriteItem{
+					Update: &dynamodb.Update{
+						TableName: aws.String(db.tableName),
+						Key: map[string]*dynamodb.AttributeValue{
+							"PK": item["PK"],
+							"SK": item["SK"],
+						},
+						ConditionExpression: aws.String("attribute_exists(#PK) AND (attribute_not_exists(#ExpiresAt) OR #ExpiresAt > :now)"),
+						UpdateExpression:    aws.String(update),
+						ExpressionAttributeNames: map[string]*string{
+							"#PK":        aws.String("PK"),
+							"#ExpiresAt": aws.String(ttlAttribute),
+						},
+						ExpressionAttributeValues: values,
+					},
+				})
+			}
+
+			var extended []SortableID
+			seen := map[SortableID]bool{}
+			for _, item := range holding {
+				option := item.ProductOptionID
+				if option == (SortableID{}) || seen[option] || gone[option] {
+					continue
+				}
+				seen[option] = true
+				extended = append(extended, option)
+				writes = append(writes, db.extendOptionHoldItem(item.ProductID, option, expiresAt))
+			}
+
+			_, err := db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: writes})
+			if err == nil {
+				break
+			}
+
+			// Items removed or expired since they were read cancel the transaction, it's tried again without them.
+			failed := false
+			for i, option := range extended {
+				if conditionFailedAt(err, len(pending)+i) {
+					gone[option] = true
+					failed = true
+				}
+			}
+			var kept []map[string]*dynamodb.AttributeValue
+			var keptHolding []BasketItem
+			for i, item := range pending {
+				if conditionFailedAt(err, i) {
+					failed = true
+					continue
+				}
+				kept = append(kept, item)
+				keptHolding = append(keptHolding, holding[i])
+			}
+			if !failed {
+				return err
+			}
+			pending, holding = kept, keptHolding
+		}
+	}
+
+	return nil
+}
+
+// GetBasketProducts fetches the products in the basket of a customer, without their options.
+func (db *DynamoDB) GetBasketProducts(customerID SortableID) ([]Product, error) {
+	items, err := db.GetBasketItems(customerID)
 	if err != nil {
 		return nil, err
 	}
-	if len(res.Items) == 0 {
+	if len(items) == 0 {
 		return nil, nil
 	}
 
-	attrs := []map[string]*dynamodb.AttributeValue{}
-	for _, att := range res.Items {
-		attrs = append(attrs, map[string]*dynamodb.AttributeValue{
-			"PK": {
-				S: aws.String(fmt.Sprintf("PRODUCT#%s", *att["ProductId"].S)),
-			},
-			"SK": {
-				S: aws.String("METADATA#"),
+	ids := make([]SortableID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductID)
+	}
+
+	return db.batchGetProducts(ids)
+}
+
+// basketPK is the partition of the basket of a customer.
+func basketPK(customerID SortableID) string {
+	return fmt.Sprintf("BASKET#%s", customerID)
+}
+
+// guestBasketPK is the partition of the basket of a shopper who hasn't logged in.
+func guestBasketPK(sessionID string) string {
+	return fmt.Sprintf("BASKET#SESSION#%s", sessionID)
+}
+
+// basketPK is the partition of the basket item belongs to.
+func (item BasketItem) basketPK() string {
+	if item.SessionID != "" {
+		return guestBasketPK(item.SessionID)
+	}
+	return basketPK(item.CustomerID)
+}
+
+// basketItem turns item into the item stored in its basket.
+func basketItem(item BasketItem) (map[string]*dynamodb.AttributeValue, error) {
+	i, err := dynamodbattribute.MarshalMap(&item)
+	if err != nil {
+		return nil, err
+	}
+	for name, av := range basketItemKey(item) {
+		i[name] = av
+	}
+	i["Type"] = &dynamodb.AttributeValue{S: aws.String("BasketItem")}
+
+	return i, nil
+}
+
+// GetBasketItems fetches the items in the basket of a customer, oldest first.
+func (db *DynamoDB) GetBasketItems(customerID SortableID) ([]BasketItem, error) {
+	return db.getBasketItems(basketPK(customerID), time.Time{})
+}
+
+// GetBasketItemsSince fetches the items added to the basket of a customer from since on, oldest first.
+// Items are found by the time in their id, which is to the second.
+func (db *DynamoDB) GetBasketItemsSince(customerID SortableID, since time.Time) ([]BasketItem, error) {
+	return db.getBasketItems(basketPK(customerID), since)
+}
+
+// GetGuestBasketItems fetches the items in the basket of a shopper who hasn't logged in, oldest first.
+func (db *DynamoDB) GetGuestBasketItems(sessionID string) ([]BasketItem, error) {
+	return db.getBasketItems(guestBasketPK(sessionID), time.Time{})
+}
+
+// getBasketItems fetches the items in the basket partition pk, only the ones added from since on unless it's zero.
+func (db *DynamoDB) getBasketItems(pk string, since time.Time) ([]BasketItem, error) {
+	// The basket partition holds more than the items, like the applied coupon.
+	keyCondition := "#PK = :pk And begins_with(#SK, :products)"
+	values := map[string]*dynamodb.AttributeValue{
+		":pk": {
+			S: aws.String(pk),
+		},
+		":products": {
+			S: aws.String("PRODUCT#"),
+		},
+		":now": {
+			N: aws.String(fmt.Sprint(db.now().Unix())),
+		},
+	}
+	if !since.IsZero() {
+		keyCondition = "#PK = :pk And #SK Between :from And :to"
+		delete(values, ":products")
+		values[":from"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("PRODUCT#%s", MinIDAt(since)))}
+		values[":to"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("PRODUCT#%s", maxSortableID))}
+	}
+
+	// Expired items are filtered out, since DynamoDB takes its time deleting them.
+	var raw []map[string]*dynamodb.AttributeValue
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		res, err := db.db.Query(&dynamodb.QueryInput{
+			TableName:              aws.String(db.tableName),
+			KeyConditionExpression: aws.String(keyCondition),
+			FilterExpression:       aws.String("attribute_not_exists(#ExpiresAt) Or #ExpiresAt > :now"),
+			ExpressionAttributeNames: map[string]*string{
+				"#PK":        aws.String("PK"),
+				"#SK":        aws.String("SK"),
+				"#ExpiresAt": aws.String(ttlAttribute),
 			},
+			ExpressionAttributeValues: values,
+			ExclusiveStartKey:         startKey,
 		})
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, res.Items...)
+
+		if len(res.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = res.LastEvaluatedKey
 	}
-	batch, err := db.db.BatchGetItem(&dynamodb.BatchGetItemInput{
-		RequestItems: map[string]*dynamodb.KeysAndAttributes{
-			db.tableName: {
-				Keys: attrs,
-			},
-		},
-	})
+
+	var items []BasketItem
+	err := dynamodbattribute.UnmarshalListOfMaps(raw, &items)
 	if err != nil {
 		return nil, err
 	}
+	for i := range items {
+		// Items added before they had an Id are still found by the id in their sort key.
+		if items[i].ID == (SortableID{}) {
+			sk := strings.TrimPrefix(stringAttribute(raw[i], "SK"), "PRODUCT#")
+			if err := items[i].ID.UnmarshalDynamoDBAttributeValue(&dynamodb.AttributeValue{S: aws.String(sk)}); err != nil {
+				return nil, err
+			}
+		}
+		if items[i].Quantity == 0 {
+			items[i].Quantity = 1
+		}
+	}
 
-	prods, ok := batch.Responses[db.tableName]
-	if !ok {
-		return nil, nil
+	return items, nil
+}
+
+// BasketLine is an item in the basket with what it costs right now.
+type BasketLine struct {
+	BasketItemID    SortableID `json:"basketItemId"`
+	ProductID       SortableID `json:"productId"`
+	ProductOptionID SortableID `json:"productOptionId"`
+	Name            string     `json:"name"`
+	Quantity        int        `json:"quantity"`
+	UnitPrice       int        `json:"unitPrice"`
+	SalePrice       int        `json:"salePrice"` // What a single one sells for, Sale when it's a discount on UnitPrice.
+	LineTotal       int        `json:"lineTotal"`
+	Weight          int        `json:"weight"` // Of the whole line.
+	// Unavailable tells why the line can't be checked out, like an archived option.
+	// Such lines aren't counted in the totals, and their LineTotal and Weight are 0.
+	Unavailable string `json:"unavailable,omitempty"`
+}
+
+// BasketSummary is what the basket of a customer would cost when checking out right now.
+// When the applied coupon can't be used CouponError tells why, and the totals are without it.
+type BasketSummary struct {
+	Lines       []BasketLine `json:"lines"`
+	Subtotal    int          `json:"subtotal"`
+	CouponCode  string       `json:"couponCode,omitempty"`
+	CouponError string       `json:"couponError,omitempty"`
+	Discount    int          `json:"discount"`
+	Tax         int          `json:"tax"`
+	Shipping    int          `json:"shipping"`
+	Total       int          `json:"total"`
+	Weight      int          `json:"weight"`
+}
+
+// GetBasketSummary works out the totals of the basket of a customer, taking sales and the applied coupon into account.
+// Items Checkout would refuse, of products or options that are archived or don't exist anymore, are flagged as Unavailable.
+func (db *DynamoDB) GetBasketSummary(customerID SortableID) (BasketSummary, error) {
+	items, err := db.GetBasketItems(customerID)
+	if err != nil {
+		return BasketSummary{}, err
+	}
+	coupon, err := db.getBasketCoupon(customerID)
+	if err != nil {
+		return BasketSummary{}, err
 	}
 
-	var products []Product
-	err = dynamodbattribute.UnmarshalListOfMaps(prods, &products)
+	return db.summarizeBasket(items, coupon)
+}
+
+// GetGuestBasketSummary works out the totals of the basket of a shopper who hasn't logged in, the way GetBasketSummary does.
+// Coupons are applied to the basket of a customer, so there's none for a guest.
+func (db *DynamoDB) GetGuestBasketSummary(sessionID string) (BasketSummary, error) {
+	if sessionID == "" {
+		return BasketSummary{}, errors.New("Expected SessionID to have a value.")
+	}
+	items, err := db.GetGuestBasketItems(sessionID)
 	if err != nil {
-		return nil, err
+		return BasketSummary{}, err
 	}
 
-	return products, nil
+	return db.summarizeBasket(items, nil)
 }
 
+// summarizeBasket works out the totals of items with coupon, which can be nil.
+func (db *DynamoDB) summarizeBasket(items []BasketItem, coupon *Coupon) (BasketSummary, error) {
+	products, options, err := db.getBasketProducts(items)
+	if err != nil {
+		return BasketSummary{}, err
+	}
+
+	var summary BasketSummary
+	for _, item := range items {
+		p, _, err := checkBasketItem(item, products, options)
+		line := BasketLine{
+			BasketItemID:    item.ID,
+			ProductID:       item.ProductID,
+			ProductOptionID: item.ProductOptionID,
+			Name:            p.Name,
+			Quantity:        item.Quantity,
+			UnitPrice:       p.Price,
+			SalePrice:       p.EffectivePrice(),
+		}
+		if err != nil {
+			line.Unavailable = err.Error()
+			summary.Lines = append(summary.Lines, line)
+			continue
+		}
+
+		line.LineTotal = p.EffectivePrice() * item.Quantity
+		line.Weight = p.Weight * item.Quantity
+		summary.Lines = append(summary.Lines, line)
+		summary.Subtotal += line.LineTotal
+		summary.Weight += line.Weight
+	}
+
+	if coupon != nil {
+		summary.CouponCode = coupon.Code
+	}
+
+	now := db.now()
+	discount, tax, shipping, err := db.pricing.charges(summary.Subtotal, coupon, now)
+	if errors.Is(err, ErrCouponNotApplicable) {
+		summary.CouponError = err.Error()
+		discount, tax, shipping, err = db.pricing.charges(summary.Subtotal, nil, now)
+	}
+	if err != nil {
+		return BasketSummary{}, err
+	}
+	summary.Discount, summary.Tax, summary.Shipping = discount, tax, shipping
+	summary.Total = summary.Subtotal - discount + tax + shipping
+
+	return summary, nil
+}
+
+// basketItemKey is the key of item in its basket.
+func basketItemKey(item BasketItem) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {
+			S: aws.String(item.basketPK()),
+		},
+		"SK": {
+			S: aws.String(fmt.Sprintf("PRODUCT#%s", item.ID)),
+		},
+	}
+}
+
+// MergeBaskets moves the guest basket of a shopper into the basket of the customer they logged in as.
+// Items of an option already in the basket of the customer add their quantity to it, the others are moved as they are.
+// Every guest item is moved in its own transaction that deletes it from the guest basket,
+// so running it again, or concurrently, never counts an item twice.
+func (db *DynamoDB) MergeBaskets(sessionID string, customerID SortableID) error {
+	if sessionID == "" {
+		return errors.New("Expected SessionID to have a value.")
+	}
+
+	guest, err := db.GetGuestBasketItems(sessionID)
+	if err != nil {
+		return err
+	}
+	if len(guest) == 0 {
+		return nil
+	}
+
+	items, err := db.GetBasketItems(customerID)
+	if err != nil {
+		return err
+	}
+	byOption := make(map[SortableID]BasketItem, len(items))
+	for _, item := range items {
+		byOption[item.ProductOptionID] = item
+	}
+
+	expiresAt := db.basketExpiry(db.now())
+	for _, g := range guest {
+		var move *dynamodb.TransactWriteItem
+		var release []*dynamodb.TransactWriteItem
+		if existing, ok := byOption[g.ProductOptionID]; ok {
+			// The guest item is merged away, so the option is held by one basket item less.
+			if g.ProductOptionID != (SortableID{}) {
+				release = append(release, db.releaseOptionItem(g.ProductID, g.ProductOptionID, 1))
+			}
+			move = &dynamodb.TransactWriteItem{
+				Update: &dynamodb.Update{
+					TableName:           aws.String(db.tableName),
+					Key:                 basketItemKey(existing),
+					ConditionExpression: aws.String("attribute_exists(#PK)"),
+					// Items without a Quantity count as 1.
+					UpdateExpression: aws.String("SET #Quantity = if_not_exists(#Quantity, :one) + :quantity"),
+					ExpressionAttributeNames: map[string]*string{
+						"#PK":       aws.String("PK"),
+						"#Quantity": aws.String("Quantity"),
+					},
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":one":      {N: aws.String("1")},
+						":quantity": {N: aws.String(fmt.Sprint(g.Quantity))},
+					},
+				},
+			}
+		} else {
+			moved := g
+			moved.SessionID = ""
+			moved.CustomerID = customerID
+			moved.ExpiresAt = expiresAt
+
+			item, err := basketItem(moved)
+			if err != nil {
+				return err
+			}
+			move = &dynamodb.TransactWriteItem{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(db.tableName),
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(#PK)"),
+					ExpressionAttributeNames: map[string]*string{
+						"#PK": aws.String("PK"),
+					},
+				},
+			}
+			byOption[g.ProductOptionID] = moved
+		}
+
+		_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+			TransactItems: append([]*dynamodb.TransactWriteItem{
+				move,
+				{
+					Delete: &dynamodb.Delete{
+						TableName:           aws.String(db.tableName),
+						Key:                 basketItemKey(g),
+						ConditionExpression: aws.String("attribute_exists(#PK)"),
+						ExpressionAttributeNames: map[string]*string{
+							"#PK": aws.String("PK"),
+						},
+					},
+				},
+			}, release...),
+		})
+		if conditionFailedAt(err, 1) {
+			continue // Somebody else merged the item already.
+		}
+		if conditionFailedAt(err, 0) || conditionFailedAt(err, 2) {
+			// The basket of the customer changed while merging, like the item being removed by checking out.
+			return ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.touchBasket(basketPK(customerID), expiresAt)
+}
@@ -2,6 +2,7 @@ package dynamodb
 
 import (
 	"testing"
+	"time"
 
 	"github.com/matryer/is"
 )
@@ -104,3 +105,279 @@ func TestGetBasketProducts(t *testing.T) {
 	is.True(p[0].Name != "A Shoe")
 	is.True(p[1].Name != "A Shoe")
 }
+
+func TestGetBasketSummary(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB(WithPricing(Pricing{TaxPercent: 10, ShippingFee: 50}))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	driver, err := tdb.AddProduct(Product{Name: "Driver", Category: "Clubs", Price: 300, Sale: 250, Weight: 400})
+	is.NoErr(err)
+	driverOption, err := tdb.AddOptionToProduct(driver.ID, Option{Color: "Black", Stock: 5})
+	is.NoErr(err)
+	balls, err := tdb.AddProduct(Product{Name: "Balls", Category: "Balls", Price: 20, Weight: 45})
+	is.NoErr(err)
+	ballsOption, err := tdb.AddOptionToProduct(balls.ID, Option{Color: "White", Stock: 50})
+	is.NoErr(err)
+	tees, err := tdb.AddProduct(Product{Name: "Tees", Category: "Tees", Price: 5, Weight: 10})
+	is.NoErr(err)
+	teesOption, err := tdb.AddOptionToProduct(tees.ID, Option{Color: "Wood", Stock: 50})
+	is.NoErr(err)
+
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: driver.ID, ProductOptionID: driverOption.ID}))
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: balls.ID, ProductOptionID: ballsOption.ID, Quantity: 3}))
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: tees.ID, ProductOptionID: teesOption.ID, Quantity: 10}))
+	_, err = tdb.ArchiveOption(tees.ID, teesOption.ID)
+	is.NoErr(err)
+
+	_, err = tdb.AddCoupon(Coupon{Code: "BIGSPENDER", Kind: CouponFixed, Value: 100, MinBasketValue: 1000})
+	is.NoErr(err)
+	_, err = tdb.ApplyCoupon(customerID, "BIGSPENDER")
+	is.NoErr(err)
+
+	summary, err := tdb.GetBasketSummary(customerID)
+	is.NoErr(err)
+	is.Equal(len(summary.Lines), 3)
+	lines := map[SortableID]BasketLine{} // Items added within the same second have no order.
+	for _, line := range summary.Lines {
+		lines[line.ProductID] = line
+	}
+	is.Equal(lines[driver.ID].UnitPrice, 300)
+	is.Equal(lines[driver.ID].SalePrice, 250)
+	is.Equal(lines[balls.ID].LineTotal, 60)
+	is.True(lines[tees.ID].Unavailable != "") // The option is archived, checking out would fail.
+	is.Equal(lines[tees.ID].LineTotal, 0)
+	is.Equal(summary.Subtotal, 310)
+	is.Equal(summary.Weight, 535)
+	is.True(summary.CouponError != "") // The basket is worth too little for the coupon.
+	is.Equal(summary.Discount, 0)
+	is.Equal(summary.Tax, 31)
+	is.Equal(summary.Total, 391)
+}
+
+func TestGetGuestBasketSummary(t *testing.T) {
+	is := is.New(t)
+	sessionID := NewSortableID().String()
+
+	tdb, err := NewTestDynamoDB(WithPricing(Pricing{ShippingFee: 50}))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Glove", Category: "Gloves", Price: 30, Weight: 80})
+	is.NoErr(err)
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Red", Stock: 10})
+	is.NoErr(err)
+	is.NoErr(tdb.AddBasketItem(BasketItem{SessionID: sessionID, ProductID: p.ID, ProductOptionID: o.ID, Quantity: 2}))
+
+	summary, err := tdb.GetGuestBasketSummary(sessionID)
+	is.NoErr(err)
+	is.Equal(len(summary.Lines), 1)
+	is.Equal(summary.Subtotal, 60)
+	is.Equal(summary.Weight, 160)
+	is.Equal(summary.Total, 110)
+
+	_, err = tdb.GetGuestBasketSummary("")
+	is.True(err != nil)
+}
+
+func TestMergeBaskets(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+	sessionID := NewSortableID().String()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Glove", Category: "Gloves", Price: 30})
+	is.NoErr(err)
+	redOption, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Red", Stock: 10})
+	is.NoErr(err)
+	greenOption, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Green", Stock: 10})
+	is.NoErr(err)
+	red, green := redOption.ID, greenOption.ID
+
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: p.ID, ProductOptionID: red, Quantity: 1}))
+	is.NoErr(tdb.AddBasketItem(BasketItem{SessionID: sessionID, ProductID: p.ID, ProductOptionID: red, Quantity: 2}))
+	is.NoErr(tdb.AddBasketItem(BasketItem{SessionID: sessionID, ProductID: p.ID, ProductOptionID: green, Quantity: 1}))
+	is.NoErr(tdb.AddBasketItem(BasketItem{SessionID: sessionID, ProductID: p.ID, ProductOptionID: green, Quantity: 4}))
+
+	is.NoErr(tdb.MergeBaskets(sessionID, customerID))
+	is.NoErr(tdb.MergeBaskets(sessionID, customerID)) // Merging again changes nothing.
+
+	guest, err := tdb.GetGuestBasketItems(sessionID)
+	is.NoErr(err)
+	is.Equal(len(guest), 0)
+
+	items, err := tdb.GetBasketItems(customerID)
+	is.NoErr(err)
+	is.Equal(len(items), 2)
+
+	quantities := map[SortableID]int{}
+	for _, item := range items {
+		quantities[item.ProductOptionID] = item.Quantity
+	}
+	is.Equal(quantities[red], 3)
+	is.Equal(quantities[green], 5)
+
+	// The merged away items don't hold their options anymore, so checking out frees them.
+	_, err = tdb.Checkout(customerID)
+	is.NoErr(err)
+	is.NoErr(tdb.DeleteOption(p.ID, red))
+	is.NoErr(tdb.DeleteOption(p.ID, green))
+}
+
+func TestAddBasketItemUnavailableOption(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Glove", Category: "Gloves", Price: 30})
+	is.NoErr(err)
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Red", Stock: 10})
+	is.NoErr(err)
+	_, err = tdb.ArchiveOption(p.ID, o.ID)
+	is.NoErr(err)
+
+	err = tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: p.ID, ProductOptionID: NewSortableID()})
+	is.Equal(err, ErrNotFound)
+
+	err = tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: p.ID, ProductOptionID: o.ID})
+	is.Equal(err, ErrArchived)
+
+	items, err := tdb.GetBasketItems(customerID)
+	is.NoErr(err)
+	is.Equal(len(items), 0)
+}
+
+func TestAddBasketItemOwner(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	err = tdb.AddBasketItem(BasketItem{ProductID: NewSortableID()})
+	is.True(err != nil) // Neither a customer nor a session.
+
+	err = tdb.AddBasketItem(BasketItem{CustomerID: NewSortableID(), SessionID: "abc", ProductID: NewSortableID()})
+	is.True(err != nil) // Both.
+
+	err = tdb.AddBasketItem(BasketItem{CustomerID: NewSortableID(), ProductID: NewSortableID()})
+	is.True(err != nil) // No option.
+}
+
+func TestBasketExpiry(t *testing.T) {
+	is := is.New(t)
+	now := time.Now()
+
+	db := &DynamoDB{basketTTL: time.Hour}
+	is.Equal(db.basketExpiry(now), now.Add(time.Hour).Unix())
+	is.Equal((&DynamoDB{}).basketExpiry(now), int64(0)) // Never expires.
+
+	is.True(isExpired(now.Unix(), now))
+	is.True(!isExpired(now.Unix()+1, now))
+	is.True(!isExpired(0, now))
+}
+
+func TestGetBasketItemsLeavesOutExpired(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	now := time.Now().Truncate(time.Second)
+	tdb, err := NewTestDynamoDB(WithBasketTTL(time.Second), WithClock(func() time.Time { return now }))
+	is.NoErr(err)
+	defer tdb.Close()
+	o := addBasketOption(t, tdb)
+
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: o.ProductID, ProductOptionID: o.ID}))
+
+	items, err := tdb.GetBasketItems(customerID)
+	is.NoErr(err)
+	is.Equal(len(items), 1)
+	is.True(items[0].ExpiresAt > 0)
+
+	now = now.Add(2 * time.Second) // Long enough to expire, too short for DynamoDB to have deleted it.
+
+	items, err = tdb.GetBasketItems(customerID)
+	is.NoErr(err)
+	is.Equal(len(items), 0)
+
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: o.ProductID, ProductOptionID: o.ID}))
+	items, err = tdb.GetBasketItems(customerID)
+	is.NoErr(err)
+	is.Equal(len(items), 1) // Touching the basket doesn't bring the expired item back.
+}
+
+func TestTouchBasket(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	now := time.Now().Truncate(time.Second)
+	tdb, err := NewTestDynamoDB(WithBasketTTL(time.Hour), WithClock(func() time.Time { return now }))
+	is.NoErr(err)
+	defer tdb.Close()
+	o := addBasketOption(t, tdb)
+
+	// More items than fits in a single transaction.
+	for i := 0; i < transactWriteLimit+5; i++ {
+		is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: o.ProductID, ProductOptionID: o.ID}))
+	}
+
+	now = now.Add(30 * time.Minute)
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: o.ProductID, ProductOptionID: o.ID}))
+
+	items, err := tdb.GetBasketItems(customerID)
+	is.NoErr(err)
+	is.Equal(len(items), transactWriteLimit+6)
+	for _, item := range items {
+		is.Equal(item.ExpiresAt, now.Add(time.Hour).Unix()) // Adding the last item kept the whole basket alive.
+	}
+}
+
+func TestGetBasketItemsSince(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	now := time.Now().Truncate(time.Second)
+	tdb, err := NewTestDynamoDB(WithClock(func() time.Time { return now }))
+	is.NoErr(err)
+	defer tdb.Close()
+	o := addBasketOption(t, tdb)
+
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: o.ProductID, ProductOptionID: o.ID}))
+	since := now.Add(time.Hour)
+	now = since
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: o.ProductID, ProductOptionID: o.ID}))
+
+	items, err := tdb.GetBasketItemsSince(customerID, since)
+	is.NoErr(err)
+	is.Equal(len(items), 1)
+	is.True(!items[0].ID.Time().Before(since))
+
+	items, err = tdb.GetBasketItems(customerID)
+	is.NoErr(err)
+	is.Equal(len(items), 2)
+}
+
+// addBasketOption adds a product with an option in stock, for tests that only need something to put in a basket.
+func addBasketOption(t *testing.T, tdb *TestDynamoDB) Option {
+	t.Helper()
+
+	p, err := tdb.AddProduct(Product{Name: "Tees", Category: "Accessories"})
+	if err != nil {
+		t.Fatalf("adding a product: %v", err)
+	}
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Color: "White", Stock: 100})
+	if err != nil {
+		t.Fatalf("adding an option: %v", err)
+	}
+
+	return o
+}
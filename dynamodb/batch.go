@@ -0,0 +1,167 @@
+package dynamodb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	// batchGetLimit is the most keys DynamoDB accepts in a single BatchGetItem call.
+	batchGetLimit = 100
+
+	// batchWriteLimit is the most requests DynamoDB accepts in a single BatchWriteItem call.
+	batchWriteLimit = 25
+
+	// transactWriteLimit is the most items DynamoDB accepts in a single TransactWriteItems call.
+	transactWriteLimit = 100
+
+	batchWriteRetries = 5
+	batchWriteBackoff = 50 * time.Millisecond
+)
+
+// batchWrite sends requests with BatchWriteItem, batchWriteLimit at a time, spread over workers.
+// Unprocessed items are retried with an exponential backoff.
+// The returned slice holds the outcome of every request at the same index, nil meaning it got written.
+func (db *DynamoDB) batchWrite(requests []*dynamodb.WriteRequest, workers int) []error {
+	errs := make([]error, len(requests))
+	if workers < 1 {
+		workers = 1
+	}
+
+	chunks := make(chan []int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				// Every chunk owns distinct indexes of errs, so there's no need to lock.
+				db.batchWriteChunk(requests, chunk, errs)
+			}
+		}()
+	}
+
+	for start := 0; start < len(requests); start += batchWriteLimit {
+		end := start + batchWriteLimit
+		if end > len(requests) {
+			end = len(requests)
+		}
+		chunk := make([]int, 0, end-start)
+		for i := start; i < end; i++ {
+			chunk = append(chunk, i)
+		}
+		chunks <- chunk
+	}
+	close(chunks)
+	wg.Wait()
+
+	return errs
+}
+
+func (db *DynamoDB) batchWriteChunk(requests []*dynamodb.WriteRequest, chunk []int, errs []error) {
+	pending := chunk
+	backoff := batchWriteBackoff
+
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt == batchWriteRetries {
+			for _, i := range pending {
+				errs[i] = ErrUnprocessed
+			}
+			return
+		}
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		batch := make([]*dynamodb.WriteRequest, 0, len(pending))
+		for _, i := range pending {
+			batch = append(batch, requests[i])
+		}
+
+		res, err := db.db.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{
+				db.tableName: batch,
+			},
+		})
+		if err != nil {
+			for _, i := range pending {
+				errs[i] = err
+			}
+			return
+		}
+
+		unprocessed := map[string]bool{}
+		for _, r := range res.UnprocessedItems[db.tableName] {
+			unprocessed[writeRequestKey(r)] = true
+		}
+
+		var next []int
+		for _, i := range pending {
+			if unprocessed[writeRequestKey(requests[i])] {
+				next = append(next, i)
+			}
+		}
+		pending = next
+	}
+}
+
+// batchGet fetches the items with the given keys with BatchGetItem, batchGetLimit at a time.
+// Unprocessed keys are retried with an exponential backoff. The items come back in no particular order,
+// and keys without an item are left out.
+func (db *DynamoDB) batchGet(keys []map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	var items []map[string]*dynamodb.AttributeValue
+
+	for start := 0; start < len(keys); start += batchGetLimit {
+		end := start + batchGetLimit
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		pending := keys[start:end]
+		backoff := batchWriteBackoff
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == batchWriteRetries {
+				return nil, ErrUnprocessed
+			}
+			if attempt > 0 {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+
+			res, err := db.db.BatchGetItem(&dynamodb.BatchGetItemInput{
+				RequestItems: map[string]*dynamodb.KeysAndAttributes{
+					db.tableName: {
+						Keys: pending,
+					},
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, res.Responses[db.tableName]...)
+
+			pending = nil
+			if unprocessed, ok := res.UnprocessedKeys[db.tableName]; ok {
+				pending = unprocessed.Keys
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// writeRequestKey identifies the item a request writes to, so unprocessed items can be matched to their request.
+func writeRequestKey(r *dynamodb.WriteRequest) string {
+	var key map[string]*dynamodb.AttributeValue
+	switch {
+	case r.PutRequest != nil:
+		key = r.PutRequest.Item
+	case r.DeleteRequest != nil:
+		key = r.DeleteRequest.Key
+	}
+
+	return stringAttribute(key, "PK") + "|" + stringAttribute(key, "SK")
+}
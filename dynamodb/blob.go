@@ -0,0 +1,76 @@
+package dynamodb
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BlobStore stores the files products refer to, like their images.
+type BlobStore interface {
+	// Put stores the content of r under key, and returns the location the blob can be referred to by.
+	Put(key string, r io.Reader) (string, error)
+	// Delete removes the blob at location.
+	// Locations the store doesn't own, or blobs that are already gone, are left alone without an error.
+	Delete(location string) error
+}
+
+// FileBlobStore is a BlobStore keeping its blobs in a directory on the local filesystem.
+// It's meant for tests and local development, locations looks like file:///tmp/blobs/products/1/image.png.
+type FileBlobStore struct {
+	dir string
+}
+
+// NewFileBlobStore creates a FileBlobStore storing blobs in dir, creating it when it doesn't exist.
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileBlobStore{dir: dir}, nil
+}
+
+// Put satisfies the BlobStore interface.
+func (s *FileBlobStore) Put(key string, r io.Reader) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	return "file://" + filepath.ToSlash(path), nil
+}
+
+// Delete satisfies the BlobStore interface.
+func (s *FileBlobStore) Delete(location string) error {
+	if !strings.HasPrefix(location, "file://") {
+		return nil
+	}
+	path := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(location, "file://")))
+	if !strings.HasPrefix(path, s.dir+string(filepath.Separator)) {
+		return nil
+	}
+
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
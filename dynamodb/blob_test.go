@@ -0,0 +1,51 @@
+package dynamodb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestFileBlobStore(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+
+	store, err := NewFileBlobStore(dir)
+	is.NoErr(err)
+
+	location, err := store.Put("products/1/image.png", strings.NewReader("png"))
+	is.NoErr(err)
+	is.Equal(location, "file://"+filepath.ToSlash(filepath.Join(dir, "products", "1", "image.png")))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "products", "1", "image.png"))
+	is.NoErr(err)
+	is.Equal(string(b), "png")
+
+	err = store.Delete(location)
+	is.NoErr(err)
+	_, err = os.Stat(filepath.Join(dir, "products", "1", "image.png"))
+	is.True(os.IsNotExist(err)) // The blob should be gone.
+
+	err = store.Delete(location)
+	is.NoErr(err) // Deleting it twice is fine.
+}
+
+func TestFileBlobStoreLeavesForeignLocationsAlone(t *testing.T) {
+	is := is.New(t)
+	outside := filepath.Join(t.TempDir(), "outside.png")
+	err := ioutil.WriteFile(outside, []byte("png"), 0644)
+	is.NoErr(err)
+
+	store, err := NewFileBlobStore(t.TempDir())
+	is.NoErr(err)
+
+	is.NoErr(store.Delete("s3://images/image.png"))
+	is.NoErr(store.Delete("file://" + filepath.ToSlash(outside)))
+
+	_, err = os.Stat(outside)
+	is.NoErr(err) // The store doesn't own the file, so it should still be there.
+}
@@ -0,0 +1,126 @@
+package dynamodb
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Checkout places an order for everything in the basket of a customer and empties the basket.
+// Every line item snapshots the name of the product, the attributes of the option and the price it sells for,
+// so later changes to the product don't rewrite the order.
+// The coupon applied to the basket, if any, is taken off and counted as used.
+// When the basket changes while checking out, nothing is written and ErrConflict is returned.
+// Archived products and options in the basket fail it with ErrArchived.
+// The options are counted off as held by the basket, but their stock is left alone.
+func (db *DynamoDB) Checkout(customerID SortableID) (Order, error) {
+	items, err := db.GetBasketItems(customerID)
+	if err != nil {
+		return Order{}, err
+	}
+	if len(items) == 0 {
+		return Order{}, ErrEmptyBasket
+	}
+
+	products, options, err := db.getBasketProducts(items)
+	if err != nil {
+		return Order{}, err
+	}
+
+	o := Order{CustomerID: customerID}
+	var removals []*dynamodb.TransactWriteItem
+	var held []BasketItem // An item for every option, the first one holding it.
+	holds := map[SortableID]int{}
+	for _, item := range items {
+		p, option, err := checkBasketItem(item, products, options)
+		if err != nil {
+			return Order{}, err
+		}
+		o.Items = append(o.Items, snapshotLineItem(p, option, item.Quantity))
+		if holds[option.ID] == 0 {
+			held = append(held, item)
+		}
+		holds[option.ID]++
+
+		removals = append(removals, &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName:           aws.String(db.tableName),
+				Key:                 basketItemKey(item),
+				ConditionExpression: aws.String("attribute_exists(#PK)"),
+				ExpressionAttributeNames: map[string]*string{
+					"#PK": aws.String("PK"),
+				},
+			},
+		})
+	}
+
+	// An option is only written once in a transaction, so the items holding it are counted off together.
+	for _, item := range held {
+		removals = append(removals, db.releaseOptionItem(item.ProductID, item.ProductOptionID, holds[item.ProductOptionID]))
+	}
+
+	coupon, err := db.getBasketCoupon(customerID)
+	if err != nil {
+		return Order{}, err
+	}
+	if coupon != nil {
+		removals = append(removals, &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName:           aws.String(db.tableName),
+				Key:                 basketCouponKey(customerID),
+				ConditionExpression: aws.String("attribute_exists(#PK)"),
+				ExpressionAttributeNames: map[string]*string{
+					"#PK": aws.String("PK"),
+				},
+			},
+		})
+	}
+
+	return db.placeOrder(o, coupon, removals)
+}
+
+// getBasketProducts fetches the products and the options of items, reading each of them once.
+func (db *DynamoDB) getBasketProducts(items []BasketItem) (map[SortableID]Product, map[SortableID]Option, error) {
+	ids := make([]SortableID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductID)
+	}
+	fetched, err := db.batchGetProducts(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	products := make(map[SortableID]Product, len(fetched))
+	for _, p := range fetched {
+		products[p.ID] = p
+	}
+
+	options, err := db.batchGetOptions(items)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return products, options, nil
+}
+
+// checkBasketItem finds the product and the option of item, failing with ErrNotFound when either doesn't exist
+// and with ErrArchived when either is archived, the way Checkout refuses them.
+func checkBasketItem(item BasketItem, products map[SortableID]Product, options map[SortableID]Option) (Product, Option, error) {
+	p, ok := products[item.ProductID]
+	if !ok {
+		return Product{}, Option{}, fmt.Errorf("Product %s in the basket: %w", item.ProductID, ErrNotFound)
+	}
+	if p.Archived {
+		return p, Option{}, fmt.Errorf("Product %s in the basket: %w", item.ProductID, ErrArchived)
+	}
+
+	option, ok := options[item.ProductOptionID]
+	if !ok {
+		return p, Option{}, fmt.Errorf("Option %s in the basket: %w", item.ProductOptionID, ErrNotFound)
+	}
+	if option.Archived {
+		return p, option, fmt.Errorf("Option %s in the basket: %w", item.ProductOptionID, ErrArchived)
+	}
+
+	return p, option, nil
+}
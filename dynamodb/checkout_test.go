@@ -0,0 +1,82 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestCheckout(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB(WithPricing(Pricing{
+		TaxPercent:       25,
+		ShippingFee:      49,
+		FreeShippingFrom: 1000,
+	}))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Driver", Category: "Clubs", Price: 300, Sale: 200})
+	is.NoErr(err)
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Red", ShaftStiffness: 5.5, Stock: 10})
+	is.NoErr(err)
+
+	err = tdb.AddBasketItem(BasketItem{
+		CustomerID:      customerID,
+		ProductID:       p.ID,
+		ProductOptionID: o.ID,
+		Quantity:        2,
+	})
+	is.NoErr(err)
+
+	order, err := tdb.Checkout(customerID)
+	is.NoErr(err)
+	is.Equal(order.Subtotal, 400) // Two at the sale price.
+	is.Equal(order.Tax, 100)
+	is.Equal(order.Shipping, 49)
+	is.Equal(order.Total, 549)
+
+	basket, err := tdb.GetBasketItems(customerID)
+	is.NoErr(err)
+	is.Equal(len(basket), 0) // Checking out empties the basket.
+
+	_, err = tdb.Checkout(customerID)
+	is.Equal(err, ErrEmptyBasket)
+
+	// Deleting the product doesn't change what got ordered.
+	is.NoErr(tdb.DeleteProduct(p.ID))
+
+	fetched, err := tdb.GetOrder(order.ID)
+	is.NoErr(err)
+	is.Equal(fetched.Total, 549)
+	is.Equal(len(fetched.Items), 1)
+	is.Equal(fetched.Items[0].Name, "Driver")
+	is.Equal(fetched.Items[0].Color, "Red")
+	is.Equal(fetched.Items[0].ShaftStiffness, 5.5)
+	is.Equal(fetched.Items[0].UnitPrice, 200)
+	is.Equal(fetched.Items[0].Quantity, 2)
+}
+
+func TestCheckoutVerifiesPurchase(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Putter", Category: "Clubs", Price: 150})
+	is.NoErr(err)
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Size: "34", Stock: 3})
+	is.NoErr(err)
+
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: p.ID, ProductOptionID: o.ID}))
+	_, err = tdb.Checkout(customerID)
+	is.NoErr(err)
+
+	review, err := tdb.AddReview(Review{ProductID: p.ID, CustomerID: customerID, Rating: 5})
+	is.NoErr(err)
+	is.True(review.VerifiedPurchase) // The customer ordered the putter.
+}
@@ -0,0 +1,292 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// CouponKind is how a coupon discounts a basket.
+type CouponKind string
+
+const (
+	// CouponPercentage takes Value percent off the basket.
+	CouponPercentage CouponKind = "percentage"
+	// CouponFixed takes Value off the basket, never more than the basket is worth.
+	CouponFixed CouponKind = "fixed"
+)
+
+// basketCouponSK is where the coupon applied to a basket is kept, next to the items of the basket.
+const basketCouponSK = "COUPON#"
+
+// Coupon is a code customers apply to their basket to get a discount at checkout.
+type Coupon struct {
+	Code           string     `json:"code" dynamodbav:"Code,omitempty"`
+	CreatedDate    time.Time  `json:"createdUtc" dynamodbav:"CreatedUtc,omitempty"`
+	Kind           CouponKind `json:"kind" dynamodbav:"Kind,omitempty"`
+	Value          int        `json:"value" dynamodbav:"Value,omitempty"`
+	MinBasketValue int        `json:"minBasketValue" dynamodbav:"MinBasketValue,omitempty"`
+	ExpiresDate    *time.Time `json:"expiresUtc,omitempty" dynamodbav:"ExpiresUtc,omitempty"`
+	UsageLimit     int        `json:"usageLimit" dynamodbav:"UsageLimit,omitempty"` // 0 means unlimited.
+	// UsageCount is how many orders used the coupon, it's only ever changed by placing an order.
+	UsageCount int `json:"usageCount" dynamodbav:"UsageCount"`
+}
+
+func (c *Coupon) validate() error {
+	c.Code = normalizeCouponCode(c.Code)
+	if c.Code == "" {
+		return errors.New("Expected Code to have a value.")
+	}
+
+	switch c.Kind {
+	case CouponPercentage:
+		if c.Value < 1 || c.Value > 100 {
+			return errors.New("Expected Value to be a percentage between 1 and 100.")
+		}
+	case CouponFixed:
+		if c.Value < 1 {
+			return errors.New("Expected Value to be positive.")
+		}
+	default:
+		return fmt.Errorf("Expected Kind to be %q or %q.", CouponPercentage, CouponFixed)
+	}
+
+	if c.MinBasketValue < 0 || c.UsageLimit < 0 {
+		return errors.New("Expected MinBasketValue and UsageLimit to not be negative.")
+	}
+
+	return nil
+}
+
+// normalizeCouponCode makes codes case insensitive.
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// couponTime is how expiries are stored and compared.
+// Whole seconds in UTC always have the same length, so the condition on the usage counter can compare them as strings.
+func couponTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// usable tells why the coupon can't be used right now, if it can't.
+func (c Coupon) usable(now time.Time) error {
+	if c.ExpiresDate != nil && !couponTime(now).Before(*c.ExpiresDate) {
+		return fmt.Errorf("%w: it expired", ErrCouponNotApplicable)
+	}
+	if c.UsageLimit > 0 && c.UsageCount >= c.UsageLimit {
+		return fmt.Errorf("%w: it has been used up", ErrCouponNotApplicable)
+	}
+	return nil
+}
+
+// discount is what the coupon takes off a basket worth subtotal.
+func (c Coupon) discount(subtotal int) (int, error) {
+	if subtotal < c.MinBasketValue {
+		return 0, fmt.Errorf("%w: the basket has to be worth at least %d", ErrCouponNotApplicable, c.MinBasketValue)
+	}
+
+	var discount int
+	switch c.Kind {
+	case CouponPercentage:
+		discount = (subtotal*c.Value + 50) / 100
+	case CouponFixed:
+		discount = c.Value
+	}
+	if discount > subtotal {
+		discount = subtotal
+	}
+
+	return discount, nil
+}
+
+// couponKey is the key of the coupon with code.
+func couponKey(code string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {
+			S: aws.String(fmt.Sprintf("COUPON#%s", code)),
+		},
+		"SK": {
+			S: aws.String("METADATA#"),
+		},
+	}
+}
+
+// AddCoupon creates a coupon, failing with ErrConflict when its code is taken.
+// Codes are case insensitive and stored in upper case.
+func (db *DynamoDB) AddCoupon(c Coupon) (Coupon, error) {
+	if err := c.validate(); err != nil {
+		return Coupon{}, err
+	}
+
+	c.CreatedDate = db.now()
+	c.UsageCount = 0
+	if c.ExpiresDate != nil {
+		expires := couponTime(*c.ExpiresDate)
+		c.ExpiresDate = &expires
+	}
+
+	item, err := dynamodbattribute.MarshalMap(&c)
+	if err != nil {
+		return Coupon{}, err
+	}
+	for name, av := range couponKey(c.Code) {
+		item[name] = av
+	}
+	item["Type"] = &dynamodb.AttributeValue{S: aws.String("coupon")}
+
+	_, err = db.db.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(db.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#PK)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#PK": aws.String("PK"),
+		},
+	})
+	if isConditionFailed(err) {
+		return Coupon{}, ErrConflict
+	}
+	if err != nil {
+		return Coupon{}, err
+	}
+
+	return c, nil
+}
+
+// GetCoupon fetches a coupon by its code.
+func (db *DynamoDB) GetCoupon(code string) (Coupon, error) {
+	res, err := db.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(db.tableName),
+		Key:       couponKey(normalizeCouponCode(code)),
+	})
+	if err != nil {
+		return Coupon{}, err
+	}
+	if res.Item == nil {
+		return Coupon{}, ErrNotFound
+	}
+
+	var c Coupon
+	err = dynamodbattribute.UnmarshalMap(res.Item, &c)
+	if err != nil {
+		return Coupon{}, err
+	}
+
+	return c, nil
+}
+
+// ApplyCoupon applies a coupon to the basket of a customer, replacing the coupon applied before.
+// The minimum basket value is only checked at checkout, since the basket can still change until then.
+func (db *DynamoDB) ApplyCoupon(customerID SortableID, code string) (Coupon, error) {
+	c, err := db.GetCoupon(code)
+	if err != nil {
+		return Coupon{}, err
+	}
+	if err := c.usable(db.now()); err != nil {
+		return Coupon{}, err
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		"PK":   {S: aws.String(basketPK(customerID))},
+		"SK":   {S: aws.String(basketCouponSK)},
+		"Type": {S: aws.String("basket_coupon")},
+		"Code": {S: aws.String(c.Code)},
+	}
+	expiresAt := db.basketExpiry(db.now())
+	if expiresAt > 0 {
+		item[ttlAttribute] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprint(expiresAt))}
+	}
+
+	_, err = db.db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(db.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return Coupon{}, err
+	}
+
+	return c, db.touchBasket(basketPK(customerID), expiresAt)
+}
+
+// RemoveCoupon takes the coupon off the basket of a customer.
+func (db *DynamoDB) RemoveCoupon(customerID SortableID) error {
+	_, err := db.db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(db.tableName),
+		Key:       basketCouponKey(customerID),
+	})
+	return err
+}
+
+// basketCouponKey is the key of the coupon applied to the basket of a customer.
+func basketCouponKey(customerID SortableID) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {
+			S: aws.String(basketPK(customerID)),
+		},
+		"SK": {
+			S: aws.String(basketCouponSK),
+		},
+	}
+}
+
+// getBasketCoupon fetches the coupon applied to the basket of a customer, nil meaning there's none.
+func (db *DynamoDB) getBasketCoupon(customerID SortableID) (*Coupon, error) {
+	res, err := db.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(db.tableName),
+		Key:       basketCouponKey(customerID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Item == nil {
+		return nil, nil
+	}
+	if av, ok := res.Item[ttlAttribute]; ok && av.N != nil {
+		expiresAt, err := strconv.ParseInt(*av.N, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if isExpired(expiresAt, db.now()) {
+			return nil, nil
+		}
+	}
+
+	code := stringAttribute(res.Item, "Code")
+	c, err := db.GetCoupon(code)
+	if err != nil {
+		return nil, fmt.Errorf("Coupon %s on the basket: %w", code, err)
+	}
+
+	return &c, nil
+}
+
+// redeemCouponItem counts an order using the coupon, as long as it hasn't expired or been used up by then.
+// Placing orders concurrently can't go over the usage limit, since the count is checked and raised in one update.
+func (db *DynamoDB) redeemCouponItem(c Coupon, now time.Time) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName: aws.String(db.tableName),
+			Key:       couponKey(c.Code),
+			ConditionExpression: aws.String("attribute_exists(#PK)" +
+				" AND (attribute_not_exists(#UsageLimit) OR #UsageCount < #UsageLimit)" +
+				" AND (attribute_not_exists(#ExpiresUtc) OR #ExpiresUtc > :now)"),
+			UpdateExpression: aws.String("ADD #UsageCount :one"),
+			ExpressionAttributeNames: map[string]*string{
+				"#PK":         aws.String("PK"),
+				"#UsageLimit": aws.String("UsageLimit"),
+				"#UsageCount": aws.String("UsageCount"),
+				"#ExpiresUtc": aws.String("ExpiresUtc"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":now": {S: aws.String(couponTime(now).Format(time.RFC3339))},
+				":one": {N: aws.String("1")},
+			},
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package dynamodb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestCouponDiscount(t *testing.T) {
+	is := is.New(t)
+
+	percentage := Coupon{Kind: CouponPercentage, Value: 10, MinBasketValue: 100}
+	discount, err := percentage.discount(255)
+	is.NoErr(err)
+	is.Equal(discount, 26) // 25.5 rounds up.
+
+	_, err = percentage.discount(99)
+	is.True(errors.Is(err, ErrCouponNotApplicable)) // Below the minimum basket value.
+
+	fixed := Coupon{Kind: CouponFixed, Value: 500}
+	discount, err = fixed.discount(300)
+	is.NoErr(err)
+	is.Equal(discount, 300) // Never more than the basket is worth.
+}
+
+func TestCouponUsable(t *testing.T) {
+	is := is.New(t)
+	now := time.Now()
+	expired := couponTime(now.Add(-time.Hour))
+
+	is.NoErr(Coupon{UsageLimit: 2, UsageCount: 1}.usable(now))
+	is.True(errors.Is(Coupon{UsageLimit: 2, UsageCount: 2}.usable(now), ErrCouponNotApplicable))
+	is.True(errors.Is(Coupon{ExpiresDate: &expired}.usable(now), ErrCouponNotApplicable))
+}
+
+func TestCouponValidation(t *testing.T) {
+	is := is.New(t)
+
+	c := Coupon{Code: " spring10 ", Kind: CouponPercentage, Value: 10}
+	is.NoErr(c.validate())
+	is.Equal(c.Code, "SPRING10")
+
+	for _, invalid := range []Coupon{
+		{Code: "X", Kind: CouponPercentage, Value: 101},
+		{Code: "X", Kind: "bogo", Value: 1},
+		{Kind: CouponFixed, Value: 1},
+	} {
+		is.True(invalid.validate() != nil)
+	}
+}
+
+func TestCheckoutWithCoupon(t *testing.T) {
+	is := is.New(t)
+	first, second := NewSortableID(), NewSortableID()
+
+	tdb, err := NewTestDynamoDB(WithPricing(Pricing{TaxPercent: 25}))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Wedge", Category: "Clubs", Price: 200})
+	is.NoErr(err)
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Size: "56", Stock: 10})
+	is.NoErr(err)
+
+	_, err = tdb.AddCoupon(Coupon{Code: "once", Kind: CouponFixed, Value: 40, UsageLimit: 1})
+	is.NoErr(err)
+	_, err = tdb.AddCoupon(Coupon{Code: "ONCE", Kind: CouponFixed, Value: 40})
+	is.Equal(err, ErrConflict) // Codes are case insensitive.
+
+	for _, customerID := range []SortableID{first, second} {
+		is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: p.ID, ProductOptionID: o.ID}))
+		_, err = tdb.ApplyCoupon(customerID, "Once")
+		is.NoErr(err)
+	}
+
+	items, err := tdb.GetBasketItems(first)
+	is.NoErr(err)
+	is.Equal(len(items), 1) // The coupon isn't an item.
+
+	order, err := tdb.Checkout(first)
+	is.NoErr(err)
+	is.Equal(order.CouponCode, "ONCE")
+	is.Equal(order.Discount, 40)
+	is.Equal(order.Tax, 40) // 25% of 160.
+	is.Equal(order.Total, 200)
+
+	_, err = tdb.Checkout(second)
+	is.True(errors.Is(err, ErrCouponNotApplicable)) // The first order used it up.
+
+	c, err := tdb.GetCoupon("once")
+	is.NoErr(err)
+	is.Equal(c.UsageCount, 1)
+}
@@ -2,6 +2,8 @@ package dynamodb
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -9,15 +11,108 @@ import (
 	"github.com/segmentio/ksuid"
 )
 
+// DefaultLowStockThreshold is the stock level options are considered low on
+// unless WithLowStockThreshold says otherwise.
+const DefaultLowStockThreshold = 5
+
+// DefaultBasketTTL is how long a basket is kept after it was last touched
+// unless WithBasketTTL says otherwise.
+const DefaultBasketTTL = 30 * 24 * time.Hour
+
+// DefaultIdempotencyTTL is how long the result of a write with an idempotency key is remembered
+// unless WithIdempotencyTTL says otherwise.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// DefaultOutboxMaxAttempts is how many times RelayOutbox tries to publish a message before giving up on it
+// unless WithOutboxMaxAttempts says otherwise.
+const DefaultOutboxMaxAttempts = 5
+
 // DynamoDB wraps AWS dynamodb.DynamoDB
 // This is to add domain logic.
 type DynamoDB struct {
 	db        *dynamodb.DynamoDB
 	tableName string
+
+	lowStockThreshold int
+	blobStore         BlobStore
+	pricing           Pricing
+	basketTTL         time.Duration
+	idempotencyTTL    time.Duration
+	outboxMaxAttempts int
+	clock             Clock
+	newID             IDGenerator
+}
+
+// Clock tells the time items are stamped with.
+type Clock func() time.Time
+
+// IDGenerator mints the IDs of new items.
+type IDGenerator func() SortableID
+
+// Setting changes the default behaviour of a DynamoDB wrapper.
+type Setting func(*DynamoDB)
+
+// WithLowStockThreshold sets the stock level under which an option is put in the low stock index.
+// Options are only re-indexed when their stock changes, so existing options pick up a new threshold lazily.
+func WithLowStockThreshold(threshold int) Setting {
+	return func(db *DynamoDB) {
+		db.lowStockThreshold = threshold
+	}
+}
+
+// WithBlobStore sets where product images are stored.
+func WithBlobStore(store BlobStore) Setting {
+	return func(db *DynamoDB) {
+		db.blobStore = store
+	}
+}
+
+// WithPricing sets the tax and shipping charged on orders.
+func WithPricing(pricing Pricing) Setting {
+	return func(db *DynamoDB) {
+		db.pricing = pricing
+	}
+}
+
+// WithBasketTTL sets how long a basket is kept after it was last touched.
+func WithBasketTTL(ttl time.Duration) Setting {
+	return func(db *DynamoDB) {
+		db.basketTTL = ttl
+	}
+}
+
+// WithIdempotencyTTL sets how long the result of a write with an idempotency key is remembered.
+// Retrying the write after that writes it again.
+func WithIdempotencyTTL(ttl time.Duration) Setting {
+	return func(db *DynamoDB) {
+		db.idempotencyTTL = ttl
+	}
+}
+
+// WithOutboxMaxAttempts sets how many times RelayOutbox tries to publish a message
+// before moving it to the dead letters, see GetDeadLetterMessages.
+func WithOutboxMaxAttempts(attempts int) Setting {
+	return func(db *DynamoDB) {
+		db.outboxMaxAttempts = attempts
+	}
+}
+
+// WithClock sets the clock the dates of items, the time in their ids and the expiry of baskets are based on, tests use it to freeze time.
+func WithClock(clock Clock) Setting {
+	return func(db *DynamoDB) {
+		db.clock = clock
+	}
+}
+
+// WithIDGenerator sets how the IDs of new items are minted, tests use it to get predictable IDs.
+func WithIDGenerator(newID IDGenerator) Setting {
+	return func(db *DynamoDB) {
+		db.newID = newID
+	}
 }
 
 // New creates a DynamoDB wrapper.
-func New(endpoint, tableName string) (*DynamoDB, error) {
+func New(endpoint, tableName string, settings ...Setting) (*DynamoDB, error) {
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable,
 	}))
@@ -26,10 +121,37 @@ func New(endpoint, tableName string) (*DynamoDB, error) {
 		Endpoint: aws.String(endpoint),
 	})
 
-	return &DynamoDB{
-		db:        svc,
-		tableName: tableName,
-	}, nil
+	db := &DynamoDB{
+		db:                svc,
+		tableName:         tableName,
+		lowStockThreshold: DefaultLowStockThreshold,
+		basketTTL:         DefaultBasketTTL,
+		idempotencyTTL:    DefaultIdempotencyTTL,
+		outboxMaxAttempts: DefaultOutboxMaxAttempts,
+		clock:             time.Now,
+	}
+	for _, s := range settings {
+		s(db)
+	}
+	if db.newID == nil {
+		// Ids tell when they were created, so they follow the clock too.
+		db.newID = func() SortableID { return newSortableIDAt(db.now()) }
+	}
+
+	return db, nil
+}
+
+// now is the time according to the clock of db.
+func (db *DynamoDB) now() time.Time { return db.clock() }
+
+// sortableTimeFormat writes times with a fixed number of fractional digits, so they sort as strings the way they sort as times.
+// time.RFC3339Nano drops trailing zeros, which doesn't.
+const sortableTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// sortableTime formats t for sort keys that have to keep the order things happened in,
+// finer grained than the seconds of a SortableID.
+func sortableTime(t time.Time) string {
+	return t.UTC().Format(sortableTimeFormat)
 }
 
 // SortableID makes the ID sortable.
@@ -38,9 +160,65 @@ type SortableID ksuid.KSUID
 // NewSortableID creates a new sortable id.
 func NewSortableID() SortableID { return SortableID(ksuid.New()) }
 
+// newSortableIDAt creates a new sortable id as if it was created at t.
+func newSortableIDAt(t time.Time) SortableID {
+	id, err := ksuid.NewRandomWithTime(t)
+	if err != nil {
+		panic(err) // Like ksuid.New, there's no id without randomness.
+	}
+	return SortableID(id)
+}
+
 // String satisfies the Stringer interface.
 func (id SortableID) String() string { return ksuid.KSUID(id).String() }
 
+// Time is when the id was created, to the second.
+func (id SortableID) Time() time.Time { return ksuid.KSUID(id).Time() }
+
+// maxSortableID sorts after every other id.
+var maxSortableID = SortableID(ksuid.Max)
+
+// The range of times an id can hold, seconds since the epoch.
+const (
+	minIDTime int64 = 1400000000
+	maxIDTime       = minIDTime + math.MaxUint32
+)
+
+// MinIDAt is the lowest id created in the same second as t, every id created from then on sorts after it.
+// Times before ids could hold give the lowest id there is.
+func MinIDAt(t time.Time) SortableID {
+	return idAt(t, 0x00)
+}
+
+// MaxIDAt is the highest id created in the same second as t, every id created before then sorts before it.
+// Times after ids could hold give the highest id there is.
+//
+// Together with MinIDAt it turns a range of times into a range of sort keys,
+// like SK BETWEEN ORDER#[MinIDAt(from)] AND ORDER#[MaxIDAt(to)].
+func MaxIDAt(t time.Time) SortableID {
+	return idAt(t, 0xff)
+}
+
+func idAt(t time.Time, fill byte) SortableID {
+	switch {
+	case t.Unix() < minIDTime:
+		return SortableID(ksuid.Nil)
+	case t.Unix() > maxIDTime:
+		return maxSortableID
+	}
+
+	payload := make([]byte, 16)
+	for i := range payload {
+		payload[i] = fill
+	}
+	id, err := ksuid.FromParts(t, payload)
+	if err != nil {
+		panic(err) // The payload always has the right length.
+	}
+
+	return SortableID(id)
+}
+
 // MarshalDynamoDBAttributeValue satisfy the dynamodbattribute.Marshaler interface.
 // By doing that I can tell DynamoDB how to handle my SortableID.
 func (id *SortableID) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
@@ -65,6 +243,79 @@ func (id *SortableID) UnmarshalDynamoDBAttributeValue(av *dynamodb.AttributeValu
 	return nil
 }
 
-func zerosPricePadding(i int) string {
+// ParseSortableID parses the string form of a SortableID, as String returns it.
+func ParseSortableID(s string) (SortableID, error) {
+	v, err := ksuid.Parse(s)
+	if err != nil {
+		return SortableID{}, err
+	}
+	return SortableID(v), nil
+}
+
+// MarshalText satisfies the encoding.TextMarshaler interface, so ids are written as strings in JSON.
+func (id SortableID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText satisfies the encoding.TextUnmarshaler interface.
+// An empty string leaves the id zero.
+func (id *SortableID) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		*id = SortableID{}
+		return nil
+	}
+
+	v, err := ParseSortableID(string(b))
+	if err != nil {
+		return err
+	}
+	*id = v
+
+	return nil
+}
+
+// queryPartition fetches every item with the partition key pk, following the pagination to the end.
+func (db *DynamoDB) queryPartition(pk string) ([]map[string]*dynamodb.AttributeValue, error) {
+	var items []map[string]*dynamodb.AttributeValue
+
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		res, err := db.db.Query(&dynamodb.QueryInput{
+			TableName:              aws.String(db.tableName),
+			KeyConditionExpression: aws.String("#PK = :pk"),
+			ExpressionAttributeNames: map[string]*string{
+				"#PK": aws.String("PK"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":pk": {
+					S: aws.String(pk),
+				},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, res.Items...)
+
+		if len(res.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		startKey = res.LastEvaluatedKey
+	}
+}
+
+// stringAttribute returns the string value of the named attribute, or "" when the item doesn't have it.
+func stringAttribute(item map[string]*dynamodb.AttributeValue, name string) string {
+	av, ok := item[name]
+	if !ok || av == nil {
+		return ""
+	}
+
+	return aws.StringValue(av.S)
+}
+
+// zerosPadding pads i with zeros, so numbers in sort keys sort as strings the way they sort as numbers.
+func zerosPadding(i int) string {
 	return fmt.Sprintf("%015d", i)
 }
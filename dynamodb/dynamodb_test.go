@@ -1,12 +1,15 @@
 package dynamodb
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
+	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/matryer/is"
 )
 
 type TestDynamoDB struct {
@@ -16,10 +19,10 @@ type TestDynamoDB struct {
 // NewTestDynamoDB connects to http://localhost:8000, and this should not change.
 // It then create a temporary test tables, with name looking like this Tewq-Test_2020-09-04_09-21-37.
 // To clean up your test resources you will have to call the Close() method.
-func NewTestDynamoDB() (*TestDynamoDB, error) {
+func NewTestDynamoDB(settings ...Setting) (*TestDynamoDB, error) {
 	tableName := fmt.Sprintf("%s_%s", "Tewq-Test", time.Now().Format("2006-01-02_15-04-05.000000"))
 
-	db, err := New("http://localhost:8000", tableName)
+	db, err := New("http://localhost:8000", tableName, settings...)
 	if err != nil {
 		return nil, err
 	}
@@ -46,63 +49,54 @@ func (t *TestDynamoDB) createTestTable() error {
 		return fmt.Errorf("Tried to run against %s, but can only run against an local instance", t.db.Endpoint)
 	}
 
-	_, err := t.db.CreateTable(&dynamodb.CreateTableInput{
-		TableName: aws.String(t.tableName),
-		AttributeDefinitions: []*dynamodb.AttributeDefinition{
-			{
-				AttributeName: aws.String("PK"),
-				AttributeType: aws.String("S"),
-			},
-			{
-				AttributeName: aws.String("SK"),
-				AttributeType: aws.String("S"),
-			},
-			{
-				AttributeName: aws.String("GSI1PK"),
-				AttributeType: aws.String("S"),
-			},
-			{
-				AttributeName: aws.String("GSI1SK"),
-				AttributeType: aws.String("S"),
-			},
-		},
-		KeySchema: []*dynamodb.KeySchemaElement{
-			{
-				AttributeName: aws.String("PK"),
-				KeyType:       aws.String("HASH"),
-			},
-			{
-				AttributeName: aws.String("SK"),
-				KeyType:       aws.String("RANGE"),
-			},
-		},
-		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
-			{
-				IndexName: aws.String("GSI1"),
-				KeySchema: []*dynamodb.KeySchemaElement{
-					{
-						AttributeName: aws.String("GSI1PK"),
-						KeyType:       aws.String("HASH"),
-					},
-					{
-						AttributeName: aws.String("GSI1SK"),
-						KeyType:       aws.String("RANGE"),
-					},
-				},
-				Projection: &dynamodb.Projection{
-					ProjectionType: aws.String(dynamodb.ProjectionTypeAll),
-				},
-				ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-					ReadCapacityUnits:  aws.Int64(10),
-					WriteCapacityUnits: aws.Int64(10),
-				},
-			},
-		},
-		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(10),
-			WriteCapacityUnits: aws.Int64(10),
-		},
-	})
+	return t.CreateTable()
+}
 
-	return err
+func TestSortableIDTime(t *testing.T) {
+	is := is.New(t)
+	at := time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC)
+
+	id := newSortableIDAt(at.Add(300 * time.Millisecond))
+	is.True(id.Time().Equal(at)) // To the second.
+
+	min, max := MinIDAt(at), MaxIDAt(at)
+	is.True(min.Time().Equal(at))
+	is.True(max.Time().Equal(at))
+	is.True(min.String() < id.String())
+	is.True(id.String() < max.String())
+	is.True(max.String() < MinIDAt(at.Add(time.Second)).String())
+}
+
+func TestSortableIDRangeClamps(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(MinIDAt(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)), SortableID{})
+	is.Equal(MaxIDAt(time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)), maxSortableID)
+}
+
+func TestSortableIDText(t *testing.T) {
+	is := is.New(t)
+	id := NewSortableID()
+
+	parsed, err := ParseSortableID(id.String())
+	is.NoErr(err)
+	is.Equal(parsed, id)
+
+	_, err = ParseSortableID("not an id")
+	is.True(err != nil)
+
+	b, err := json.Marshal(struct {
+		ID SortableID `json:"id"`
+	}{id})
+	is.NoErr(err)
+	is.Equal(string(b), fmt.Sprintf(`{"id":"%s"}`, id))
+
+	var decoded struct {
+		ID SortableID `json:"id"`
+	}
+	is.NoErr(json.Unmarshal(b, &decoded))
+	is.Equal(decoded.ID, id)
+
+	is.NoErr(json.Unmarshal([]byte(`{"id":""}`), &decoded))
+	is.Equal(decoded.ID, SortableID{})
 }
@@ -0,0 +1,60 @@
+package dynamodb
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var (
+	// ErrNotFound is returned when the item an operation works on doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when an item changed between reading and writing it.
+	ErrConflict = errors.New("the item was changed concurrently")
+	// ErrAlreadyReviewed is returned when a customer tries to review a product a second time.
+	ErrAlreadyReviewed = errors.New("the customer has already reviewed the product")
+	// ErrAlreadyModerated is returned when moderating a review that isn't pending anymore.
+	ErrAlreadyModerated = errors.New("the review has already been moderated")
+	// ErrInvalidTransition is returned when an order isn't allowed to move on to the requested status.
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	// ErrEmptyBasket is returned when checking out a basket without any items in it.
+	ErrEmptyBasket = errors.New("the basket is empty")
+	// ErrArchived is returned when ordering a product or an option that has been archived.
+	ErrArchived = errors.New("the product or option is archived")
+	// ErrOptionInUse is returned when deleting an option that's still in a basket.
+	ErrOptionInUse = errors.New("the option is in a basket")
+	// ErrCouponNotApplicable is returned when a coupon can't be used, because it expired, got used up or the basket is worth too little.
+	ErrCouponNotApplicable = errors.New("the coupon can't be applied")
+	// ErrIdempotencyKeyReused is returned when an idempotency key is used again for another kind of write or another request.
+	ErrIdempotencyKeyReused = errors.New("the idempotency key was used for another request")
+	// ErrUnprocessed is returned for batch writes DynamoDB still refused to process after retrying.
+	ErrUnprocessed = errors.New("DynamoDB left the write unprocessed after retrying")
+	// ErrNoBlobStore is returned by operations on files when the DynamoDB wrapper got no BlobStore.
+	ErrNoBlobStore = errors.New("no blob store configured, see WithBlobStore")
+	// ErrNegativeStock is returned when an option is given a stock under zero.
+	ErrNegativeStock = errors.New("the stock can't be negative")
+	// ErrTooManySearchTerms is returned when the name and description of a product have more terms than can be indexed.
+	ErrTooManySearchTerms = errors.New("the product has too many search terms")
+	// ErrInvalidPaginationKey is returned when a PaginationKey isn't one a previous page returned.
+	ErrInvalidPaginationKey = errors.New("malformed pagination key")
+	// ErrInvalidIdempotencyKey is returned when an idempotency key is longer than the store accepts.
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+)
+
+// isConditionFailed tells if err is DynamoDB refusing a write because its ConditionExpression didn't hold.
+func isConditionFailed(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// conditionFailedAt tells if err is a cancelled transaction, where the ConditionExpression of the i:th item didn't hold.
+func conditionFailedAt(err error, i int) bool {
+	var cancelled *dynamodb.TransactionCanceledException
+	if !errors.As(err, &cancelled) || i >= len(cancelled.CancellationReasons) {
+		return false
+	}
+
+	return aws.StringValue(cancelled.CancellationReasons[i].Code) == "ConditionalCheckFailed"
+}
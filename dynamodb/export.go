@@ -0,0 +1,198 @@
+package dynamodb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// restoreWorkers is how many BatchWriteItem calls ImportTable keeps in flight.
+const restoreWorkers = 4
+
+// ExportTable scans the whole table with segments parallel scans, and writes every item
+// as a line of DynamoDB JSON to w, the same format the AWS CLI uses: {"PK":{"S":"PRODUCT#..."}}.
+// The items are written untouched, so ImportTable restores them with their keys and indexes as they were.
+// It returns how many items got exported.
+func (db *DynamoDB) ExportTable(w io.Writer, segments int) (int, error) {
+	if segments < 1 {
+		segments = 1
+	}
+
+	items := make(chan map[string]*dynamodb.AttributeValue)
+	done := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() { stopOnce.Do(func() { close(done) }) } // Both a scan and the writer failing stop the export.
+	scanErrs := make(chan error, segments)
+
+	var wg sync.WaitGroup
+	for segment := 0; segment < segments; segment++ {
+		wg.Add(1)
+		go func(segment int) {
+			defer wg.Done()
+			err := db.scanSegment(segment, segments, items, done)
+			if err != nil {
+				stop()
+			}
+			scanErrs <- err
+		}(segment)
+	}
+	go func() {
+		wg.Wait()
+		close(items)
+		close(scanErrs)
+	}()
+
+	// A single writer, since w isn't safe to use from several goroutines.
+	var count int
+	var writeErr error
+	bw := bufio.NewWriter(w)
+	for item := range items {
+		select {
+		case <-done:
+			continue // Drain the scanners so they can notice done and stop.
+		default:
+		}
+
+		b, err := json.Marshal(encodeAttributeValues(item))
+		if err == nil {
+			_, err = fmt.Fprintf(bw, "%s\n", b)
+		}
+		if err != nil {
+			writeErr = err
+			stop()
+			continue
+		}
+		count++
+	}
+
+	for err := range scanErrs {
+		if err != nil && writeErr == nil {
+			writeErr = err
+		}
+	}
+	if writeErr != nil {
+		return count, writeErr
+	}
+
+	return count, bw.Flush()
+}
+
+func (db *DynamoDB) scanSegment(segment, segments int, items chan<- map[string]*dynamodb.AttributeValue, done <-chan struct{}) error {
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		res, err := db.db.Scan(&dynamodb.ScanInput{
+			TableName:         aws.String(db.tableName),
+			Segment:           aws.Int64(int64(segment)),
+			TotalSegments:     aws.Int64(int64(segments)),
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, item := range res.Items {
+			select {
+			case items <- item:
+			case <-done:
+				return nil
+			}
+		}
+
+		if len(res.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		startKey = res.LastEvaluatedKey
+	}
+}
+
+// ImportTable writes every item of an ExportTable file into the table as it is, overwriting items with the same keys.
+// It returns how many items got written.
+func (db *DynamoDB) ImportTable(r io.Reader) (int, error) {
+	var count int
+	var requests []*dynamodb.WriteRequest
+	var lines []int
+
+	flush := func() error {
+		for i, err := range db.batchWrite(requests, restoreWorkers) {
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lines[i], err)
+			}
+			count++
+		}
+		requests, lines = requests[:0], lines[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // A DynamoDB item is at most 400KB.
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var item map[string]*dynamodb.AttributeValue
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return count, fmt.Errorf("line %d: %w", line, err)
+		}
+		requests = append(requests, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}})
+		lines = append(lines, line)
+
+		if len(requests) == batchWriteLimit*restoreWorkers {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+
+	return count, flush()
+}
+
+// encodeAttributeValues turns an item into DynamoDB JSON.
+// dynamodb.AttributeValue already decodes from it, but encoding it would write every unset type as null.
+func encodeAttributeValues(item map[string]*dynamodb.AttributeValue) map[string]interface{} {
+	out := make(map[string]interface{}, len(item))
+	for name, av := range item {
+		out[name] = encodeAttributeValue(av)
+	}
+	return out
+}
+
+func encodeAttributeValue(av *dynamodb.AttributeValue) map[string]interface{} {
+	switch {
+	case av.S != nil:
+		return map[string]interface{}{"S": *av.S}
+	case av.N != nil:
+		return map[string]interface{}{"N": *av.N}
+	case av.B != nil:
+		return map[string]interface{}{"B": av.B}
+	case av.BOOL != nil:
+		return map[string]interface{}{"BOOL": *av.BOOL}
+	case av.NULL != nil:
+		return map[string]interface{}{"NULL": *av.NULL}
+	case av.SS != nil:
+		return map[string]interface{}{"SS": aws.StringValueSlice(av.SS)}
+	case av.NS != nil:
+		return map[string]interface{}{"NS": aws.StringValueSlice(av.NS)}
+	case av.BS != nil:
+		return map[string]interface{}{"BS": av.BS}
+	case av.L != nil:
+		l := make([]interface{}, 0, len(av.L))
+		for _, v := range av.L {
+			l = append(l, encodeAttributeValue(v))
+		}
+		return map[string]interface{}{"L": l}
+	case av.M != nil:
+		return map[string]interface{}{"M": encodeAttributeValues(av.M)}
+	}
+
+	return map[string]interface{}{}
+}
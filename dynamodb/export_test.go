@@ -0,0 +1,76 @@
+package dynamodb
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/matryer/is"
+)
+
+func TestEncodeAttributeValuesRoundTrip(t *testing.T) {
+	is := is.New(t)
+	item := map[string]*dynamodb.AttributeValue{
+		"PK":     {S: aws.String("PRODUCT#1")},
+		"Empty":  {S: aws.String("")},
+		"Price":  {N: aws.String("1000")},
+		"Image":  {B: []byte("png")},
+		"Sale":   {BOOL: aws.Bool(false)},
+		"Gone":   {NULL: aws.Bool(true)},
+		"Tags":   {SS: aws.StringSlice([]string{"a", "b"})},
+		"Sizes":  {NS: aws.StringSlice([]string{"1", "2"})},
+		"Nested": {M: map[string]*dynamodb.AttributeValue{"Color": {S: aws.String("Red")}}},
+		"List":   {L: []*dynamodb.AttributeValue{{N: aws.String("1")}, {S: aws.String("two")}}},
+		"None":   {L: []*dynamodb.AttributeValue{}},
+	}
+
+	b, err := json.Marshal(encodeAttributeValues(item))
+	is.NoErr(err)
+
+	var decoded map[string]*dynamodb.AttributeValue
+	err = json.Unmarshal(b, &decoded)
+	is.NoErr(err)
+	is.Equal(decoded, item)
+}
+
+func TestExportAndImportTable(t *testing.T) {
+	is := is.New(t)
+
+	source, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer source.Close()
+
+	// Prepare data to get exported
+	for i := 0; i < 30; i++ {
+		p, err := source.AddProduct(Product{Name: "Golf Club", Category: "Clubs", Price: i})
+		is.NoErr(err)
+		_, err = source.AddOptionToProduct(p.ID, Option{Color: "Red", Stock: 1})
+		is.NoErr(err)
+	}
+
+	var snapshot bytes.Buffer
+	exported, err := source.ExportTable(&snapshot, 3)
+	is.NoErr(err)
+	is.Equal(exported, 120) // 30 products with an option and two search tokens each.
+
+	target, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer target.Close()
+
+	imported, err := target.ImportTable(&snapshot)
+	is.NoErr(err)
+	is.Equal(imported, exported)
+
+	fetched, _, err := target.GetProductsByCategory(&GetProductsByCategoryInput{
+		Category:        "Clubs",
+		PaginationLimit: 50,
+	})
+	is.NoErr(err)
+	is.True(len(fetched) == 30) // The GSI1 keys came along.
+
+	low, _, err := target.ListLowStockOptions(&ListLowStockOptionsInput{PaginationLimit: 50})
+	is.NoErr(err)
+	is.True(len(low) == 30) // And so did the GSI2 keys.
+}
@@ -0,0 +1,206 @@
+package dynamodb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// maxIdempotencyKeyLength keeps keys well within what DynamoDB allows in a partition key.
+const maxIdempotencyKeyLength = 255
+
+// WriteOption changes how a single write is done.
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	idempotencyKey string
+}
+
+// WithIdempotencyKey makes a write happen once for the key. Repeating the write with the same key,
+// like a client retrying a request it didn't get the response of, returns the result of the first write instead.
+// Keys are remembered for as long as WithIdempotencyTTL says.
+func WithIdempotencyKey(key string) WriteOption {
+	return func(o *writeOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+func newWriteOptions(opts []WriteOption) (writeOptions, error) {
+	var o writeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.idempotencyKey) > maxIdempotencyKeyLength {
+		return writeOptions{}, fmt.Errorf("%w: expected it to be at most %d characters", ErrInvalidIdempotencyKey, maxIdempotencyKeyLength)
+	}
+
+	return o, nil
+}
+
+// idempotencyRecord remembers the result of a write done with an idempotency key.
+type idempotencyRecord struct {
+	Key         string    `dynamodbav:"Key"`
+	Operation   string    `dynamodbav:"Operation"`
+	RequestHash string    `dynamodbav:"RequestHash,omitempty"`
+	Response    string    `dynamodbav:"Response"`
+	CreatedDate time.Time `dynamodbav:"CreatedUtc"`
+	ExpiresAt   int64     `dynamodbav:"ExpiresAt,omitempty"`
+}
+
+func idempotencyKey(key string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {S: aws.String(fmt.Sprintf("IDEMPOTENCY#%s", key))},
+		"SK": {S: aws.String("METADATA#")},
+	}
+}
+
+// requestHash identifies request, the input of a write, so a replay can tell it got the same one.
+func requestHash(request interface{}) (string, error) {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// putOnce puts the new item, unless o has an idempotency key that was used before.
+// With a key the put is written together with a record of response, the result of operation for request,
+// and a repeated put unmarshals the recorded result into response and tells it was replayed.
+// Repeating the key with another operation or request fails with ErrIdempotencyKeyReused instead.
+// An item with the same key existing already fails the put with ErrConflict.
+//
+// The extra writes are done in the same transaction, ahead of the put, so a condition of the i:th of them
+// failing can be told by conditionFailedAt(err, i).
+func (db *DynamoDB) putOnce(o writeOptions, operation string, request interface{}, item map[string]*dynamodb.AttributeValue, response interface{}, extra ...*dynamodb.TransactWriteItem) (bool, error) {
+	put := &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName:                aws.String(db.tableName),
+			Item:                     item,
+			ConditionExpression:      aws.String("attribute_not_exists(#PK)"),
+			ExpressionAttributeNames: map[string]*string{"#PK": aws.String("PK")},
+		},
+	}
+
+	if o.idempotencyKey == "" {
+		if len(extra) == 0 {
+			return false, db.putNew(item)
+		}
+
+		_, err := db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+			TransactItems: append(extra, put),
+		})
+		if conditionFailedAt(err, len(extra)) {
+			return false, ErrConflict
+		}
+		return false, err
+	}
+
+	hash, err := requestHash(request)
+	if err != nil {
+		return false, err
+	}
+	b, err := json.Marshal(response)
+	if err != nil {
+		return false, err
+	}
+	now := db.now()
+	r := idempotencyRecord{
+		Key:         o.idempotencyKey,
+		Operation:   operation,
+		RequestHash: hash,
+		Response:    string(b),
+		CreatedDate: now,
+	}
+	if db.idempotencyTTL > 0 {
+		r.ExpiresAt = now.Add(db.idempotencyTTL).Unix()
+	}
+	record, err := dynamodbattribute.MarshalMap(&r)
+	if err != nil {
+		return false, err
+	}
+	for k, v := range idempotencyKey(o.idempotencyKey) {
+		record[k] = v
+	}
+	record["Type"] = &dynamodb.AttributeValue{S: aws.String("idempotency_record")}
+
+	_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: append(extra,
+			&dynamodb.TransactWriteItem{
+				Put: &dynamodb.Put{
+					TableName: aws.String(db.tableName),
+					Item:      record,
+					// DynamoDB deletes expired records lazily, they don't count until then.
+					ConditionExpression: aws.String("attribute_not_exists(#PK) Or #ExpiresAt <= :now"),
+					ExpressionAttributeNames: map[string]*string{
+						"#PK":        aws.String("PK"),
+						"#ExpiresAt": aws.String(ttlAttribute),
+					},
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":now": {N: aws.String(fmt.Sprint(now.Unix()))},
+					},
+				},
+			},
+			put,
+		),
+	})
+	if conditionFailedAt(err, len(extra)) {
+		return true, db.replay(o.idempotencyKey, operation, hash, response)
+	}
+	if conditionFailedAt(err, len(extra)+1) {
+		return false, ErrConflict
+	}
+
+	return false, err
+}
+
+// putNew puts item, an item with the same key existing already fails with ErrConflict.
+func (db *DynamoDB) putNew(item map[string]*dynamodb.AttributeValue) error {
+	_, err := db.db.PutItem(&dynamodb.PutItemInput{
+		TableName:                aws.String(db.tableName),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#PK)"),
+		ExpressionAttributeNames: map[string]*string{"#PK": aws.String("PK")},
+	})
+	if isConditionFailed(err) {
+		return ErrConflict
+	}
+
+	return err
+}
+
+// replay unmarshals the result recorded for key into response, when it was recorded for the same operation and request.
+func (db *DynamoDB) replay(key, operation, hash string, response interface{}) error {
+	res, err := db.db.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(db.tableName),
+		Key:            idempotencyKey(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	if res.Item == nil {
+		// Expired and deleted in between writing and reading it.
+		return ErrConflict
+	}
+
+	var r idempotencyRecord
+	if err := dynamodbattribute.UnmarshalMap(res.Item, &r); err != nil {
+		return err
+	}
+	if r.Operation != operation {
+		return fmt.Errorf("%w: %s", ErrIdempotencyKeyReused, r.Operation)
+	}
+	if r.RequestHash != hash {
+		return fmt.Errorf("%w: %s with another request", ErrIdempotencyKeyReused, r.Operation)
+	}
+
+	return json.Unmarshal([]byte(r.Response), response)
+}
@@ -0,0 +1,102 @@
+package dynamodb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestAddProductIdempotencyKey(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	first, err := tdb.AddProduct(Product{Name: "Wedge", Category: "Clubs", Price: 120}, WithIdempotencyKey("add-wedge"))
+	is.NoErr(err)
+
+	retried, err := tdb.AddProduct(Product{Name: "Wedge", Category: "Clubs", Price: 120}, WithIdempotencyKey("add-wedge"))
+	is.NoErr(err)
+	is.Equal(retried.ID, first.ID) // The first result is returned again.
+
+	products, _, err := tdb.GetProductsByCategory(&GetProductsByCategoryInput{Category: "Clubs"})
+	is.NoErr(err)
+	is.Equal(len(products), 1)
+
+	other, err := tdb.AddProduct(Product{Name: "Wedge", Category: "Clubs", Price: 120}, WithIdempotencyKey("add-another-wedge"))
+	is.NoErr(err)
+	is.True(other.ID != first.ID)
+
+	o, err := tdb.AddOptionToProduct(first.ID, Option{Size: "Regular", Stock: 5})
+	is.NoErr(err)
+	err = tdb.AddBasketItem(BasketItem{CustomerID: NewSortableID(), ProductID: first.ID, ProductOptionID: o.ID}, WithIdempotencyKey("add-wedge"))
+	is.True(errors.Is(err, ErrIdempotencyKeyReused))
+}
+
+func TestAddBasketItemIdempotencyKey(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+	o := addBasketOption(t, tdb)
+
+	item := BasketItem{CustomerID: customerID, ProductID: o.ProductID, ProductOptionID: o.ID, Quantity: 2}
+	is.NoErr(tdb.AddBasketItem(item, WithIdempotencyKey("basket-1")))
+	is.NoErr(tdb.AddBasketItem(item, WithIdempotencyKey("basket-1")))
+
+	items, err := tdb.GetBasketItems(customerID)
+	is.NoErr(err)
+	is.Equal(len(items), 1)
+}
+
+func TestIdempotencyKeyReusedForAnotherRequest(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	_, err = tdb.AddProduct(Product{Name: "Wedge", Category: "Clubs", Price: 120}, WithIdempotencyKey("add-wedge"))
+	is.NoErr(err)
+
+	_, err = tdb.AddProduct(Product{Name: "Putter", Category: "Clubs", Price: 80}, WithIdempotencyKey("add-wedge"))
+	is.True(errors.Is(err, ErrIdempotencyKeyReused)) // Not the wedge added the first time.
+
+	products, _, err := tdb.GetProductsByCategory(&GetProductsByCategoryInput{Category: "Clubs"})
+	is.NoErr(err)
+	is.Equal(len(products), 1)
+	is.Equal(products[0].Name, "Wedge")
+}
+
+func TestRequestHash(t *testing.T) {
+	is := is.New(t)
+
+	a, err := requestHash(Product{Name: "Wedge"})
+	is.NoErr(err)
+	b, err := requestHash(Product{Name: "Wedge"})
+	is.NoErr(err)
+	c, err := requestHash(Product{Name: "Putter"})
+	is.NoErr(err)
+	is.Equal(a, b)
+	is.True(a != c)
+}
+
+func TestWriteOptions(t *testing.T) {
+	is := is.New(t)
+
+	o, err := newWriteOptions(nil)
+	is.NoErr(err)
+	is.Equal(o.idempotencyKey, "")
+
+	o, err = newWriteOptions([]WriteOption{WithIdempotencyKey("key")})
+	is.NoErr(err)
+	is.Equal(o.idempotencyKey, "key")
+
+	_, err = newWriteOptions([]WriteOption{WithIdempotencyKey(strings.Repeat("k", maxIdempotencyKeyLength+1))})
+	is.True(err != nil)
+}
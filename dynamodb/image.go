@@ -0,0 +1,185 @@
+package dynamodb
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Registers the formats UploadProductImage accepts.
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ThumbnailSize is the longest side, in pixels, of the thumbnails UploadProductImage generates.
+const ThumbnailSize = 200
+
+// UploadProductImage stores a PNG, JPEG or GIF image as the image of a product, together with a thumbnail of it.
+// Image and Thumbnail of the product are swapped to the new blobs in a single update, and the old blobs are deleted after.
+// The returned Product doesn't include its options.
+func (db *DynamoDB) UploadProductImage(productID SortableID, r io.Reader) (Product, error) {
+	if db.blobStore == nil {
+		return Product{}, ErrNoBlobStore
+	}
+
+	original, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Product{}, err
+	}
+	img, format, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return Product{}, fmt.Errorf("Could not decode the image: %w", err)
+	}
+
+	current, err := db.getProductMetadata(productID)
+	if err != nil {
+		return Product{}, err
+	}
+
+	var thumb bytes.Buffer
+	if err := png.Encode(&thumb, thumbnail(img, ThumbnailSize)); err != nil {
+		return Product{}, err
+	}
+
+	// Every upload gets its own prefix, so the old blobs are still around until the product points to the new ones.
+	prefix := fmt.Sprintf("products/%s/%s", productID, db.newID())
+	imageLocation, err := db.blobStore.Put(fmt.Sprintf("%s/original.%s", prefix, format), bytes.NewReader(original))
+	if err != nil {
+		return Product{}, err
+	}
+	thumbnailLocation, err := db.blobStore.Put(fmt.Sprintf("%s/thumbnail.png", prefix), &thumb)
+	if err != nil {
+		db.blobStore.Delete(imageLocation)
+		return Product{}, err
+	}
+
+	updated, err := db.swapProductImage(current, imageLocation, thumbnailLocation)
+	if err != nil {
+		db.blobStore.Delete(imageLocation)
+		db.blobStore.Delete(thumbnailLocation)
+		return Product{}, err
+	}
+
+	// The product doesn't point to the old blobs anymore, failing to delete them only leaves garbage behind.
+	for _, location := range []string{current.Image, current.Thumbnail} {
+		if location != "" {
+			db.blobStore.Delete(location)
+		}
+	}
+
+	return updated, nil
+}
+
+// swapProductImage points the product to the new image and thumbnail, as long as nobody else did it since current was read.
+func (db *DynamoDB) swapProductImage(current Product, imageLocation, thumbnailLocation string) (Product, error) {
+	condition := "attribute_exists(#PK)"
+	values := map[string]*dynamodb.AttributeValue{
+		":image": {
+			S: aws.String(imageLocation),
+		},
+		":thumbnail": {
+			S: aws.String(thumbnailLocation),
+		},
+	}
+	if current.Image == "" {
+		condition += " AND attribute_not_exists(#Image)"
+	} else {
+		condition += " AND #Image = :currentImage"
+		values[":currentImage"] = &dynamodb.AttributeValue{S: aws.String(current.Image)}
+	}
+	if current.Thumbnail == "" {
+		condition += " AND attribute_not_exists(#ThumbNail)"
+	} else {
+		condition += " AND #ThumbNail = :currentThumbnail"
+		values[":currentThumbnail"] = &dynamodb.AttributeValue{S: aws.String(current.Thumbnail)}
+	}
+
+	res, err := db.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(db.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {
+				S: aws.String(fmt.Sprintf("PRODUCT#%s", current.ID)),
+			},
+			"SK": {
+				S: aws.String("METADATA#"),
+			},
+		},
+		ConditionExpression: aws.String(condition),
+		UpdateExpression:    aws.String("SET #Image = :image, #ThumbNail = :thumbnail"),
+		ExpressionAttributeNames: map[string]*string{
+			"#PK":        aws.String("PK"),
+			"#Image":     aws.String("Image"),
+			"#ThumbNail": aws.String("ThumbNail"),
+		},
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if isConditionFailed(err) {
+		return Product{}, ErrConflict
+	}
+	if err != nil {
+		return Product{}, err
+	}
+
+	var p Product
+	err = dynamodbattribute.UnmarshalMap(res.Attributes, &p)
+	if err != nil {
+		return Product{}, err
+	}
+
+	return p, nil
+}
+
+// thumbnail scales img down to fit within size x size pixels, keeping its aspect ratio.
+// Every thumbnail pixel is the average of the pixels it covers in the original.
+// Images already small enough are returned as they are.
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	tw, th := size, size
+	if w > h {
+		th = h * size / w
+	} else {
+		tw = w * size / h
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,106 @@
+package dynamodb
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestThumbnail(t *testing.T) {
+	is := is.New(t)
+
+	wide := thumbnail(image.NewRGBA(image.Rect(0, 0, 800, 400)), 200)
+	is.Equal(wide.Bounds().Dx(), 200)
+	is.Equal(wide.Bounds().Dy(), 100)
+
+	tall := thumbnail(image.NewRGBA(image.Rect(0, 0, 300, 600)), 200)
+	is.Equal(tall.Bounds().Dx(), 100)
+	is.Equal(tall.Bounds().Dy(), 200)
+
+	small := image.NewRGBA(image.Rect(0, 0, 50, 50))
+	is.Equal(thumbnail(small, 200), small) // Small images are left alone.
+}
+
+func TestThumbnailAveragesPixels(t *testing.T) {
+	is := is.New(t)
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		c := color.RGBA{A: 255}
+		if x%2 == 0 {
+			c.R = 255
+		}
+		img.Set(x, 0, c)
+		img.Set(x, 1, c)
+	}
+
+	r, _, _, _ := thumbnail(img, 2).At(0, 0).RGBA()
+	is.Equal(r>>8, uint32(127)) // Half red, half black.
+}
+
+func TestUploadProductImage(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	store, err := NewFileBlobStore(dir)
+	is.NoErr(err)
+
+	tdb, err := NewTestDynamoDB(WithBlobStore(store))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+
+	first, err := tdb.UploadProductImage(p.ID, testPNG(t, 800, 600))
+	is.NoErr(err)
+	is.True(strings.HasPrefix(first.Image, "file://"))
+	is.True(strings.HasPrefix(first.Thumbnail, "file://"))
+
+	second, err := tdb.UploadProductImage(p.ID, testPNG(t, 100, 100))
+	is.NoErr(err)
+	is.True(second.Image != first.Image)
+
+	_, err = os.Stat(locationPath(first.Image))
+	is.True(os.IsNotExist(err)) // The replaced image should be cleaned up.
+	_, err = os.Stat(locationPath(second.Thumbnail))
+	is.NoErr(err)
+
+	err = tdb.DeleteProduct(p.ID)
+	is.NoErr(err)
+	_, err = os.Stat(locationPath(second.Image))
+	is.True(os.IsNotExist(err)) // Deleting the product deletes its images.
+}
+
+func TestUploadProductImageNotAnImage(t *testing.T) {
+	is := is.New(t)
+	store, err := NewFileBlobStore(t.TempDir())
+	is.NoErr(err)
+
+	tdb, err := NewTestDynamoDB(WithBlobStore(store))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+
+	_, err = tdb.UploadProductImage(p.ID, strings.NewReader("not an image"))
+	is.True(err != nil)
+}
+
+func testPNG(t *testing.T, w, h int) *bytes.Buffer {
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return &b
+}
+
+func locationPath(location string) string {
+	return filepath.FromSlash(strings.TrimPrefix(location, "file://"))
+}
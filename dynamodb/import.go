@@ -0,0 +1,385 @@
+package dynamodb
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// importWorkers is how many TransactWriteItems calls ImportProducts keeps in flight.
+const importWorkers = 4
+
+// ImportFormat is the file format ImportProducts reads.
+type ImportFormat string
+
+const (
+	// ImportCSV reads one product option per row, see ImportProducts for the columns.
+	ImportCSV ImportFormat = "csv"
+	// ImportJSONL reads one Product per line, with its options, using the Product json tags.
+	ImportJSONL ImportFormat = "jsonl"
+)
+
+// ImportRowResult is the outcome of importing a single row.
+type ImportRowResult struct {
+	Row       int // The row in the file starting at 1, a CSV header counts as row 1.
+	ProductID SortableID
+	OptionIDs []SortableID
+	Err       error
+}
+
+// ImportReport tells how every row of an import went.
+type ImportReport struct {
+	Rows      []ImportRowResult
+	Succeeded int
+	Failed    int
+}
+
+// importGroup is a product of an import with the rows adding it and its options.
+// The product, its search tokens and its options are written in a single transaction, the product first.
+type importGroup struct {
+	id     SortableID
+	writes []*dynamodb.TransactWriteItem
+	rows   []int
+	err    error // Why the group couldn't be written, none of it is then.
+}
+
+// importRow is a parsed row waiting to be written.
+type importRow struct {
+	line    int
+	ref     string // Rows sharing a ref belongs to the same product, only used by CSV.
+	product Product
+	err     error
+}
+
+// ImportProducts reads products and options from r and writes them to DynamoDB.
+//
+// A CSV file needs a header row, columns are matched by the Product and Option json tags:
+// productRef, name, category, description, image, thumbNail, price, weight, sale,
+// size, socket, color, stock and shaftStiffness. Every row holds at most one option,
+// rows with the same productRef adds their options to the product of the first of them.
+//
+// Products and options from a JSON Lines file keep their id and createdUtc when they have them.
+// Every product is written together with its search tokens and options in one transaction, with the condition
+// the product doesn't exist yet. A product with the same id existing already fails its rows with ErrConflict
+// instead of being overwritten, and a product is never left without its options, so an import can be run again.
+// A product with more options and search terms together than fit in a transaction fails its rows.
+//
+// Rows are validated and written independently, a broken row doesn't stop the import.
+// The returned error is only set when r can't be read at all.
+func (db *DynamoDB) ImportProducts(r io.Reader, format ImportFormat) (ImportReport, error) {
+	var rows []importRow
+	var err error
+	switch format {
+	case ImportCSV:
+		rows, err = parseImportCSV(r)
+	case ImportJSONL:
+		rows, err = parseImportJSONL(r)
+	default:
+		return ImportReport{}, fmt.Errorf("Unknown import format %q.", format)
+	}
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	results := make([]ImportRowResult, len(rows))
+	var products []*importGroup
+
+	// A product belongs to every row adding an option to it, so its rows are shared by the writes of the product.
+	groups := map[string]*importGroup{}
+
+	for i, row := range rows {
+		results[i] = ImportRowResult{Row: row.line, Err: row.err}
+		if row.err != nil {
+			continue
+		}
+
+		g, grouped := groups[row.ref]
+		if !grouped || row.ref == "" {
+			if err := validateImportProduct(row.product); err != nil {
+				results[i].Err = err
+				continue
+			}
+
+			p := row.product
+			if p.ID == (SortableID{}) {
+				p.ID = db.newID()
+			}
+			if p.CreatedDate.IsZero() {
+				p.CreatedDate = db.now()
+			}
+			p = p.withoutMaintainedFields()
+
+			item, err := productItem(p)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+
+			g = &importGroup{id: p.ID, rows: []int{i}}
+			g.writes = append(g.writes, &dynamodb.TransactWriteItem{
+				Put: &dynamodb.Put{
+					TableName:                aws.String(db.tableName),
+					Item:                     item,
+					ConditionExpression:      aws.String("attribute_not_exists(#PK)"),
+					ExpressionAttributeNames: map[string]*string{"#PK": aws.String("PK")},
+				},
+			})
+			groups[row.ref] = g
+			products = append(products, g)
+
+			if !p.Archived { // Archived products aren't searchable.
+				g.writes = append(g.writes, db.indexWrites(p)...)
+			}
+		} else {
+			if err := validateImportOptions(row.product.Options); err != nil {
+				results[i].Err = err
+				continue
+			}
+			g.rows = append(g.rows, i)
+		}
+
+		results[i].ProductID = g.id
+
+		var options []*dynamodb.TransactWriteItem
+		for _, o := range row.product.Options {
+			if o.ID == (SortableID{}) {
+				o.ID = db.newID()
+			}
+			if o.CreatedDate.IsZero() {
+				o.CreatedDate = db.now()
+			}
+			o.ProductID = g.id
+
+			item, err := db.optionItem(o)
+			if err != nil {
+				results[i].Err = err
+				break
+			}
+			options = append(options, &dynamodb.TransactWriteItem{
+				Put: &dynamodb.Put{TableName: aws.String(db.tableName), Item: item},
+			})
+			results[i].OptionIDs = append(results[i].OptionIDs, o.ID)
+		}
+		if results[i].Err == nil { // A broken row adds none of its options.
+			g.writes = append(g.writes, options...)
+		}
+	}
+
+	db.writeImportGroups(products)
+	for _, g := range products {
+		if g.err == nil {
+			continue
+		}
+		for _, row := range g.rows {
+			if results[row].Err == nil {
+				results[row].Err = g.err
+			}
+		}
+	}
+
+	report := ImportReport{Rows: results}
+	for _, r := range results {
+		if r.Err != nil {
+			report.Failed++
+			continue
+		}
+		report.Succeeded++
+	}
+
+	return report, nil
+}
+
+// writeImportGroups writes every group in a transaction of its own, unless its product exists already,
+// and sets the err of those that failed.
+func (db *DynamoDB) writeImportGroups(groups []*importGroup) {
+	next := make(chan *importGroup)
+	var wg sync.WaitGroup
+	for w := 0; w < importWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range next {
+				// Every group is written by a single worker, so there's no need to lock.
+				g.err = db.writeImportGroup(g)
+			}
+		}()
+	}
+
+	for _, g := range groups {
+		next <- g
+	}
+	close(next)
+	wg.Wait()
+}
+
+func (db *DynamoDB) writeImportGroup(g *importGroup) error {
+	if len(g.writes) > transactWriteLimit {
+		return fmt.Errorf("Expected product %s to have at most %d options and search terms together, got %d.", g.id, transactWriteLimit-1, len(g.writes)-1)
+	}
+
+	_, err := db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: g.writes,
+	})
+	if conditionFailedAt(err, 0) {
+		return ErrConflict
+	}
+
+	return err
+}
+
+func validateImportProduct(p Product) error {
+	if p.Name == "" {
+		return errors.New("Expected Name to have a value.")
+	}
+	if p.Category == "" {
+		return errors.New("Expected Category to have a value.")
+	}
+	if p.Price < 0 || p.Weight < 0 || p.Sale < 0 {
+		return errors.New("Expected Price, Weight and Sale not to be negative.")
+	}
+	if err := validateProductTerms(p); err != nil {
+		return err
+	}
+
+	return validateImportOptions(p.Options)
+}
+
+func validateImportOptions(options []Option) error {
+	for _, o := range options {
+		if o.Stock < 0 {
+			return ErrNegativeStock
+		}
+	}
+
+	return nil
+}
+
+func parseImportJSONL(r io.Reader) ([]importRow, error) {
+	var rows []importRow
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		row := importRow{line: line}
+		row.err = json.Unmarshal(scanner.Bytes(), &row.product)
+		rows = append(rows, row)
+	}
+
+	return rows, scanner.Err()
+}
+
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("Expected the CSV header to have a name column.")
+	}
+
+	var rows []importRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		row := importRow{line: line}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			row.err = err
+			rows = append(rows, row)
+			continue
+		}
+
+		row.ref, row.product, row.err = parseImportRecord(columns, record)
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseImportRecord turns a CSV record into a product holding the option of that row, if it has one.
+func parseImportRecord(columns map[string]int, record []string) (string, Product, error) {
+	field := func(name string) string {
+		i, ok := columns[strings.ToLower(name)]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	var err error
+	number := func(name string) int {
+		v := field(name)
+		if v == "" || err != nil {
+			return 0
+		}
+		n, convErr := strconv.Atoi(v)
+		if convErr != nil {
+			err = fmt.Errorf("Expected %s to be a whole number, got %q.", name, v)
+		}
+		return n
+	}
+
+	p := Product{
+		Name:        field("name"),
+		Category:    field("category"),
+		Description: field("description"),
+		Image:       field("image"),
+		Thumbnail:   field("thumbNail"),
+		Price:       number("price"),
+		Weight:      number("weight"),
+		Sale:        number("sale"),
+	}
+
+	o := Option{
+		Size:   field("size"),
+		Socket: field("socket"),
+		Color:  field("color"),
+		Stock:  number("stock"),
+	}
+	if v := field("shaftStiffness"); v != "" {
+		f, convErr := strconv.ParseFloat(v, 64)
+		if convErr != nil && err == nil {
+			err = fmt.Errorf("Expected shaftStiffness to be a number, got %q.", v)
+		}
+		o.ShaftStiffness = f
+	}
+	if err != nil {
+		return "", Product{}, err
+	}
+
+	hasOption := false
+	for _, name := range []string{"size", "socket", "color", "stock", "shaftStiffness"} {
+		if field(name) != "" {
+			hasOption = true
+		}
+	}
+	if hasOption {
+		p.Options = []Option{o}
+	}
+
+	return field("productRef"), p, nil
+}
@@ -0,0 +1,173 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	is := is.New(t)
+	file := `productRef,name,category,price,color,stock,shaftStiffness
+club,Golf Club,Clubs,1000,Red,2,11.5
+club,Golf Club,Clubs,1000,Green,3,
+,Shoe,Shoes,abc,,,
+,Shoe,Shoes,500,,,
+`
+
+	rows, err := parseImportCSV(strings.NewReader(file))
+	is.NoErr(err)
+	is.True(len(rows) == 4)
+
+	is.NoErr(rows[0].err)
+	is.Equal(rows[0].line, 2) // The header is the first line.
+	is.Equal(rows[0].ref, "club")
+	is.Equal(rows[0].product.Price, 1000)
+	is.Equal(rows[0].product.Options[0].ShaftStiffness, 11.5)
+
+	is.True(rows[2].err != nil) // abc isn't a price.
+
+	is.NoErr(rows[3].err)
+	is.True(len(rows[3].product.Options) == 0) // The row has no option columns filled in.
+}
+
+func TestParseImportCSVWithoutHeader(t *testing.T) {
+	is := is.New(t)
+
+	_, err := parseImportCSV(strings.NewReader(""))
+	is.True(err != nil)
+}
+
+func TestParseImportJSONL(t *testing.T) {
+	is := is.New(t)
+	file := `{"name":"Golf Club","category":"Clubs","options":[{"color":"Red","stock":2}]}
+
+{"name":
+`
+
+	rows, err := parseImportJSONL(strings.NewReader(file))
+	is.NoErr(err)
+	is.True(len(rows) == 2) // Blank lines are skipped.
+
+	is.NoErr(rows[0].err)
+	is.Equal(rows[0].product.Options[0].Color, "Red")
+
+	is.True(rows[1].err != nil)
+	is.Equal(rows[1].line, 3)
+}
+
+func TestImportProductsCSV(t *testing.T) {
+	is := is.New(t)
+	file := `productRef,name,category,price,color,stock
+club,Golf Club,Clubs,1000,Red,2
+club,,,,Green,3
+,,Shoes,500,,
+`
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	report, err := tdb.ImportProducts(strings.NewReader(file), ImportCSV)
+	is.NoErr(err)
+	is.Equal(report.Succeeded, 2)
+	is.Equal(report.Failed, 1) // The shoe doesn't have a name.
+	is.True(report.Rows[2].Err != nil)
+
+	is.Equal(report.Rows[0].ProductID, report.Rows[1].ProductID) // Both rows share the club.
+
+	p, err := tdb.GetProduct(report.Rows[0].ProductID)
+	is.NoErr(err)
+	is.Equal(p.Name, "Golf Club")
+	is.True(len(p.Options) == 2)
+}
+
+func TestImportProductsJSONL(t *testing.T) {
+	is := is.New(t)
+
+	// More products than fits in a single BatchWriteItem call.
+	var file strings.Builder
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&file, `{"name":"Club %d","category":"Clubs","price":%d,"options":[{"color":"Red","stock":1}]}`+"\n", i, i)
+	}
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	report, err := tdb.ImportProducts(strings.NewReader(file.String()), ImportJSONL)
+	is.NoErr(err)
+	is.Equal(report.Succeeded, 30)
+	is.Equal(report.Failed, 0)
+
+	fetched, _, err := tdb.GetProductsByCategory(&GetProductsByCategoryInput{
+		Category:        "Clubs",
+		PaginationLimit: 50,
+	})
+	is.NoErr(err)
+	is.True(len(fetched) == 30)
+}
+
+func TestImportProductsExistingID(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	existing, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs", Price: 1000})
+	is.NoErr(err)
+
+	file := fmt.Sprintf(`{"id":"%s","name":"Overwritten","category":"Clubs","options":[{"color":"Red","stock":1}]}
+{"name":"Golf Shoe","category":"Shoes"}
+`, existing.ID)
+
+	report, err := tdb.ImportProducts(strings.NewReader(file), ImportJSONL)
+	is.NoErr(err)
+	is.Equal(report.Succeeded, 1)
+	is.Equal(report.Failed, 1)
+	is.True(errors.Is(report.Rows[0].Err, ErrConflict))
+
+	fetched, err := tdb.GetProduct(existing.ID)
+	is.NoErr(err)
+	is.Equal(fetched.Name, "Golf Club") // Left as it was, without the option of the import.
+	is.True(len(fetched.Options) == 0)
+}
+
+func TestImportProductsTooManyOptions(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	id := NewSortableID()
+	options := make([]string, transactWriteLimit)
+	for i := range options {
+		options[i] = fmt.Sprintf(`{"color":"Color%d","stock":1}`, i)
+	}
+	file := fmt.Sprintf(`{"id":"%s","name":"Golf Club","category":"Clubs","options":[%s]}
+`, id, strings.Join(options, ","))
+
+	report, err := tdb.ImportProducts(strings.NewReader(file), ImportJSONL)
+	is.NoErr(err)
+	is.Equal(report.Failed, 1)
+
+	items, err := tdb.queryPartition(fmt.Sprintf("PRODUCT#%s", id))
+	is.NoErr(err)
+	is.Equal(len(items), 0) // Nothing of the product got written.
+}
+
+func TestImportProductsUnknownFormat(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	_, err = tdb.ImportProducts(strings.NewReader(""), ImportFormat("xml"))
+	is.True(err != nil)
+}
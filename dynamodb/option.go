@@ -0,0 +1,292 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const (
+	// basketRefsAttribute counts the basket items holding an option, so deleting it can be conditioned on it not being in use.
+	basketRefsAttribute = "BasketRefs"
+	// basketHeldUntilAttribute is when the last basket holding an option expires, in seconds since the epoch.
+	// Expiring baskets don't count themselves off BasketRefs, so after this the option isn't in use either way.
+	basketHeldUntilAttribute = "BasketHeldUntil"
+)
+
+// GetOption fetches a single option of a product.
+func (db *DynamoDB) GetOption(productID, optionID SortableID) (Option, error) {
+	res, err := db.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(db.tableName),
+		Key:       optionKey(productID, optionID),
+	})
+	if err != nil {
+		return Option{}, err
+	}
+	if res.Item == nil {
+		return Option{}, ErrNotFound
+	}
+
+	var o Option
+	err = dynamodbattribute.UnmarshalMap(res.Item, &o)
+	if err != nil {
+		return Option{}, err
+	}
+
+	return o, nil
+}
+
+// UpdateOptionInput holds the fields of an option to change, nil fields are left as they are.
+type UpdateOptionInput struct {
+	Size           *string
+	Socket         *string
+	Color          *string
+	ShaftStiffness *float64
+	Stock          *int
+}
+
+func (in *UpdateOptionInput) validate() error {
+	if in.Size == nil && in.Socket == nil && in.Color == nil && in.ShaftStiffness == nil && in.Stock == nil {
+		return errors.New("Expected at least one field to update.")
+	}
+	if in.Stock != nil && *in.Stock < 0 {
+		return ErrNegativeStock
+	}
+
+	return nil
+}
+
+// UpdateOption changes the fields of an option input has, leaving the rest alone.
+// Changing the stock keeps the low stock index up to date, and a stock under the low stock threshold
+// writes a TopicStockLow message to the outbox in the same transaction.
+func (db *DynamoDB) UpdateOption(productID, optionID SortableID, input UpdateOptionInput) (Option, error) {
+	if err := input.validate(); err != nil {
+		return Option{}, err
+	}
+
+	var set, remove []string
+	names := map[string]*string{
+		"#PK": aws.String("PK"),
+	}
+	values := map[string]*dynamodb.AttributeValue{}
+	field := func(name string, av *dynamodb.AttributeValue, zero bool) {
+		names["#"+name] = aws.String(name)
+		if zero {
+			// Empty values aren't stored, the same way AddOptionToProduct leaves them out.
+			remove = append(remove, "#"+name)
+			return
+		}
+		set = append(set, fmt.Sprintf("#%s = :%s", name, strings.ToLower(name)))
+		values[":"+strings.ToLower(name)] = av
+	}
+	if input.Size != nil {
+		field("Size", &dynamodb.AttributeValue{S: input.Size}, *input.Size == "")
+	}
+	if input.Socket != nil {
+		field("Socket", &dynamodb.AttributeValue{S: input.Socket}, *input.Socket == "")
+	}
+	if input.Color != nil {
+		field("Color", &dynamodb.AttributeValue{S: input.Color}, *input.Color == "")
+	}
+	if input.ShaftStiffness != nil {
+		field("ShaftStiffness", &dynamodb.AttributeValue{N: aws.String(fmt.Sprint(*input.ShaftStiffness))}, *input.ShaftStiffness == 0)
+	}
+
+	var low *dynamodb.TransactWriteItem
+	if input.Stock != nil {
+		stock := *input.Stock
+		field("Stock", &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", stock))}, stock == 0)
+		names["#GSI2PK"] = aws.String("GSI2PK")
+		names["#GSI2SK"] = aws.String("GSI2SK")
+		if db.isLowStock(stock) {
+			set = append(set, "#GSI2PK = :gsi2pk", "#GSI2SK = :gsi2sk")
+			values[":gsi2pk"] = &dynamodb.AttributeValue{S: aws.String(lowStockPK)}
+			values[":gsi2sk"] = &dynamodb.AttributeValue{S: aws.String(lowStockSK(stock, optionID))}
+
+			var err error
+			low, err = db.outboxItem(TopicStockLow, StockLow{ProductID: productID, OptionID: optionID, Stock: stock}, db.now())
+			if err != nil {
+				return Option{}, err
+			}
+		} else {
+			remove = append(remove, "#GSI2PK", "#GSI2SK")
+		}
+	}
+
+	var update []string
+	if len(set) > 0 {
+		update = append(update, "SET "+strings.Join(set, ", "))
+	}
+	if len(remove) > 0 {
+		update = append(update, "REMOVE "+strings.Join(remove, ", "))
+	}
+	if len(values) == 0 {
+		values = nil // DynamoDB refuses an empty map.
+	}
+
+	writes := []*dynamodb.TransactWriteItem{
+		{
+			Update: &dynamodb.Update{
+				TableName:                 aws.String(db.tableName),
+				Key:                       optionKey(productID, optionID),
+				ConditionExpression:       aws.String("attribute_exists(#PK)"),
+				UpdateExpression:          aws.String(strings.Join(update, " ")),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		},
+	}
+	if low != nil {
+		writes = append(writes, low)
+	}
+
+	_, err := db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: writes,
+	})
+	if conditionFailedAt(err, 0) {
+		return Option{}, ErrNotFound
+	}
+	if err != nil {
+		return Option{}, err
+	}
+
+	// Transactions can't return the updated item.
+	res, err := db.db.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(db.tableName),
+		Key:            optionKey(productID, optionID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return Option{}, err
+	}
+
+	var option Option
+	err = dynamodbattribute.UnmarshalMap(res.Item, &option)
+	if err != nil {
+		return Option{}, err
+	}
+
+	return option, nil
+}
+
+// DeleteOption deletes an option of a product, unless it's in a basket that hasn't expired, which returns ErrOptionInUse.
+// Archive the option with ArchiveOption to stop it from being added to baskets first.
+//
+// Adding the option to a basket counts it on the option, in the same transaction, and checking out counts it off again,
+// so the delete is conditioned on the count and can't race with the option being added to a basket.
+// Basket items added before they were counted aren't known of.
+// Baskets don't reserve stock and checking out doesn't take any, so the stock of the option doesn't matter.
+func (db *DynamoDB) DeleteOption(productID, optionID SortableID) error {
+	_, err := db.db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(db.tableName),
+		Key:       optionKey(productID, optionID),
+		ConditionExpression: aws.String("attribute_exists(#PK) And " +
+			"(attribute_not_exists(#BasketRefs) Or #BasketRefs <= :zero Or #BasketHeldUntil <= :now)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#PK":              aws.String("PK"),
+			"#BasketRefs":      aws.String(basketRefsAttribute),
+			"#BasketHeldUntil": aws.String(basketHeldUntilAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":zero": {N: aws.String("0")},
+			":now":  {N: aws.String(fmt.Sprint(db.now().Unix()))},
+		},
+	})
+	if isConditionFailed(err) {
+		if _, err := db.GetOption(productID, optionID); err != nil {
+			return err
+		}
+		return ErrOptionInUse
+	}
+
+	return err
+}
+
+// holdOptionItem is the update counting one more basket item holding an option, until expiresAt, 0 meaning for good.
+// Its condition fails when the option doesn't exist or is archived, see optionUnavailable.
+func (db *DynamoDB) holdOptionItem(productID, optionID SortableID, expiresAt int64) *dynamodb.TransactWriteItem {
+	update := "SET #BasketRefs = if_not_exists(#BasketRefs, :zero) + :one, #BasketHeldUntil = :expiresAt"
+	values := map[string]*dynamodb.AttributeValue{
+		":zero":      {N: aws.String("0")},
+		":one":       {N: aws.String("1")},
+		":expiresAt": {N: aws.String(fmt.Sprint(expiresAt))},
+	}
+	if expiresAt == 0 {
+		update = "SET #BasketRefs = if_not_exists(#BasketRefs, :zero) + :one REMOVE #BasketHeldUntil"
+		delete(values, ":expiresAt")
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName:           aws.String(db.tableName),
+			Key:                 optionKey(productID, optionID),
+			ConditionExpression: aws.String("attribute_exists(#PK) And attribute_not_exists(#Archived)"),
+			UpdateExpression:    aws.String(update),
+			ExpressionAttributeNames: map[string]*string{
+				"#PK":              aws.String("PK"),
+				"#Archived":        aws.String("Archived"),
+				"#BasketRefs":      aws.String(basketRefsAttribute),
+				"#BasketHeldUntil": aws.String(basketHeldUntilAttribute),
+			},
+			ExpressionAttributeValues: values,
+		},
+	}
+}
+
+// extendOptionHoldItem is the update keeping an option held until expiresAt, when the basket holding it is touched.
+func (db *DynamoDB) extendOptionHoldItem(productID, optionID SortableID, expiresAt int64) *dynamodb.TransactWriteItem {
+	update := "SET #BasketHeldUntil = :expiresAt"
+	values := map[string]*dynamodb.AttributeValue{
+		":expiresAt": {N: aws.String(fmt.Sprint(expiresAt))},
+	}
+	if expiresAt == 0 {
+		update = "REMOVE #BasketHeldUntil"
+		values = nil
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName:           aws.String(db.tableName),
+			Key:                 optionKey(productID, optionID),
+			ConditionExpression: aws.String("attribute_exists(#PK)"),
+			UpdateExpression:    aws.String(update),
+			ExpressionAttributeNames: map[string]*string{
+				"#PK":              aws.String("PK"),
+				"#BasketHeldUntil": aws.String(basketHeldUntilAttribute),
+			},
+			ExpressionAttributeValues: values,
+		},
+	}
+}
+
+// releaseOptionItem is the update counting n basket items holding an option off, like when they're checked out.
+func (db *DynamoDB) releaseOptionItem(productID, optionID SortableID, n int) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName:           aws.String(db.tableName),
+			Key:                 optionKey(productID, optionID),
+			ConditionExpression: aws.String("attribute_exists(#PK)"),
+			UpdateExpression:    aws.String("SET #BasketRefs = if_not_exists(#BasketRefs, :zero) - :n"),
+			ExpressionAttributeNames: map[string]*string{
+				"#PK":         aws.String("PK"),
+				"#BasketRefs": aws.String(basketRefsAttribute),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":zero": {N: aws.String("0")},
+				":n":    {N: aws.String(fmt.Sprint(n))},
+			},
+		},
+	}
+}
+
+// optionUnavailable tells why the condition of holdOptionItem failed, ErrNotFound or ErrArchived.
+func (db *DynamoDB) optionUnavailable(productID, optionID SortableID) error {
+	if _, err := db.GetOption(productID, optionID); err != nil {
+		return err
+	}
+	return ErrArchived
+}
@@ -0,0 +1,121 @@
+package dynamodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestGetAndUpdateOption(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB(WithLowStockThreshold(3))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Iron Set", Category: "Clubs"})
+	is.NoErr(err)
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Size: "Regular", Color: "Silver", ShaftStiffness: 1.5, Stock: 10})
+	is.NoErr(err)
+
+	fetched, err := tdb.GetOption(p.ID, o.ID)
+	is.NoErr(err)
+	is.Equal(fetched.Color, "Silver")
+
+	color, empty, stock := "Black", "", 2
+	updated, err := tdb.UpdateOption(p.ID, o.ID, UpdateOptionInput{Color: &color, Size: &empty, Stock: &stock})
+	is.NoErr(err)
+	is.Equal(updated.Color, "Black")
+	is.Equal(updated.Size, "")
+	is.Equal(updated.ShaftStiffness, 1.5) // Left alone.
+	is.Equal(updated.Stock, 2)
+
+	low, _, err := tdb.ListLowStockOptions(&ListLowStockOptionsInput{})
+	is.NoErr(err)
+	is.Equal(len(low), 1)
+
+	_, err = tdb.UpdateOption(p.ID, o.ID, UpdateOptionInput{})
+	is.True(err != nil) // Nothing to update.
+
+	_, err = tdb.UpdateOption(p.ID, NewSortableID(), UpdateOptionInput{Color: &color})
+	is.Equal(err, ErrNotFound)
+	_, err = tdb.GetOption(p.ID, NewSortableID())
+	is.Equal(err, ErrNotFound)
+}
+
+func TestAddOptionToMissingProduct(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	_, err = tdb.AddOptionToProduct(NewSortableID(), Option{Color: "Red"})
+	is.Equal(err, ErrNotFound)
+}
+
+func TestDeleteOption(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Towel", Category: "Accessories"})
+	is.NoErr(err)
+	inBasket, err := tdb.AddOptionToProduct(p.ID, Option{Color: "White"})
+	is.NoErr(err)
+	unused, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Green"})
+	is.NoErr(err)
+
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: p.ID, ProductOptionID: inBasket.ID}))
+
+	err = tdb.DeleteOption(p.ID, inBasket.ID)
+	is.Equal(err, ErrOptionInUse)
+
+	is.NoErr(tdb.DeleteOption(p.ID, unused.ID))
+	_, err = tdb.GetOption(p.ID, unused.ID)
+	is.Equal(err, ErrNotFound)
+
+	err = tdb.DeleteOption(p.ID, unused.ID)
+	is.Equal(err, ErrNotFound)
+
+	// Once the basket is checked out the option isn't in it anymore.
+	_, err = tdb.Checkout(customerID)
+	is.NoErr(err)
+	is.NoErr(tdb.DeleteOption(p.ID, inBasket.ID))
+}
+
+func TestDeleteOptionHeldByExpiredBasket(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	start := time.Now().Truncate(time.Second)
+	now := start
+	tdb, err := NewTestDynamoDB(WithBasketTTL(time.Hour), WithClock(func() time.Time { return now }))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Towel", Category: "Accessories"})
+	is.NoErr(err)
+	white, err := tdb.AddOptionToProduct(p.ID, Option{Color: "White"})
+	is.NoErr(err)
+	green, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Green"})
+	is.NoErr(err)
+
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: p.ID, ProductOptionID: white.ID}))
+
+	// Adding another item keeps the whole basket, and the white towel in it, for another hour.
+	now = start.Add(30 * time.Minute)
+	is.NoErr(tdb.AddBasketItem(BasketItem{CustomerID: customerID, ProductID: p.ID, ProductOptionID: green.ID}))
+
+	now = start.Add(70 * time.Minute)
+	err = tdb.DeleteOption(p.ID, white.ID)
+	is.Equal(err, ErrOptionInUse)
+
+	// The basket expired without being checked out, so it doesn't hold the option anymore.
+	now = start.Add(100 * time.Minute)
+	is.NoErr(tdb.DeleteOption(p.ID, white.ID))
+}
@@ -0,0 +1,574 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// OrderStatus is where an order is in its lifecycle.
+type OrderStatus string
+
+const (
+	// OrderPlaced is where every order starts, waiting to be paid.
+	OrderPlaced OrderStatus = "placed"
+	// OrderPaid orders are paid for and waiting to be shipped.
+	OrderPaid OrderStatus = "paid"
+	// OrderShipped orders are on their way to the customer.
+	OrderShipped OrderStatus = "shipped"
+	// OrderDelivered orders have reached the customer, they can still be refunded.
+	OrderDelivered OrderStatus = "delivered"
+	// OrderCancelled orders were called off before being paid.
+	OrderCancelled OrderStatus = "cancelled"
+	// OrderRefunded orders had their payment given back.
+	OrderRefunded OrderStatus = "refunded"
+)
+
+// orderTransitions lists the statuses an order can move on to from every status.
+// Cancelled and refunded orders are done, nothing comes after them.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPlaced:    {OrderPaid, OrderCancelled},
+	OrderPaid:      {OrderShipped, OrderRefunded},
+	OrderShipped:   {OrderDelivered},
+	OrderDelivered: {OrderRefunded},
+}
+
+// CanTransitionTo tells if an order with status s is allowed to move on to status to.
+func (s OrderStatus) CanTransitionTo(to OrderStatus) bool {
+	for _, next := range orderTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Order is what a customer has bought.
+// The totals are worked out when the order is placed, and never change after.
+type Order struct {
+	ID          SortableID      `json:"id" dynamodbav:"Id,omitempty"`
+	CustomerID  SortableID      `json:"customerId" dynamodbav:"CustomerId,omitempty"`
+	CreatedDate time.Time       `json:"createdUtc" dynamodbav:"CreatedUtc,omitempty"`
+	UpdatedDate time.Time       `json:"updatedUtc" dynamodbav:"UpdatedUtc,omitempty"`
+	Status      OrderStatus     `json:"status" dynamodbav:"Status,omitempty"`
+	Subtotal    int             `json:"subtotal" dynamodbav:"Subtotal"`
+	CouponCode  string          `json:"couponCode,omitempty" dynamodbav:"CouponCode,omitempty"`
+	Discount    int             `json:"discount" dynamodbav:"Discount"`
+	Tax         int             `json:"tax" dynamodbav:"Tax"`
+	Shipping    int             `json:"shipping" dynamodbav:"Shipping"`
+	Total       int             `json:"total" dynamodbav:"Total"`
+	Items       []OrderLineItem `json:"items" dynamodbav:"-"`
+}
+
+// OrderLineItem is a product in an order.
+// It's a snapshot of the product and option as they were when the order got placed,
+// so later changes to the product don't rewrite the order.
+type OrderLineItem struct {
+	ID             SortableID `json:"id" dynamodbav:"Id,omitempty"`
+	OrderID        SortableID `json:"orderId" dynamodbav:"OrderId,omitempty"`
+	ProductID      SortableID `json:"productId" dynamodbav:"ProductId,omitempty"`
+	OptionID       SortableID `json:"optionId" dynamodbav:"OptionId,omitempty"`
+	Name           string     `json:"name" dynamodbav:"Name,omitempty"`
+	Size           string     `json:"size" dynamodbav:"Size,omitempty"`
+	Socket         string     `json:"socket" dynamodbav:"Socket,omitempty"`
+	Color          string     `json:"color" dynamodbav:"Color,omitempty"`
+	ShaftStiffness float64    `json:"shaftStiffness" dynamodbav:"ShaftStiffness,omitempty"`
+	UnitPrice      int        `json:"unitPrice" dynamodbav:"UnitPrice"`
+	Quantity       int        `json:"quantity" dynamodbav:"Quantity"`
+	LineTotal      int        `json:"lineTotal" dynamodbav:"LineTotal"`
+}
+
+// snapshotLineItem is the line item for quantity of the option of p, at the price p sells for right now.
+func snapshotLineItem(p Product, o Option, quantity int) OrderLineItem {
+	return OrderLineItem{
+		ProductID:      p.ID,
+		OptionID:       o.ID,
+		Name:           p.Name,
+		Size:           o.Size,
+		Socket:         o.Socket,
+		Color:          o.Color,
+		ShaftStiffness: o.ShaftStiffness,
+		UnitPrice:      p.EffectivePrice(),
+		Quantity:       quantity,
+	}
+}
+
+// OrderStatusChange is an entry in the audit history of an order.
+// The first entry of every order has no From, it's the order getting placed.
+type OrderStatusChange struct {
+	ID          SortableID  `json:"id" dynamodbav:"Id,omitempty"`
+	OrderID     SortableID  `json:"orderId" dynamodbav:"OrderId,omitempty"`
+	From        OrderStatus `json:"from" dynamodbav:"From,omitempty"`
+	To          OrderStatus `json:"to" dynamodbav:"To,omitempty"`
+	ChangedDate time.Time   `json:"changedUtc" dynamodbav:"ChangedUtc,omitempty"`
+}
+
+// orderItem turns o into the order item stored in the partition of its customer.
+func orderItem(o Order) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := dynamodbattribute.MarshalMap(&o)
+	if err != nil {
+		return nil, err
+	}
+	item["Type"] = &dynamodb.AttributeValue{S: aws.String("order")}
+	item["PK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("USER#%s", o.CustomerID))}
+	item["SK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("ORDER#%s", o.ID))}
+	item["GSI1PK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("ORDER#%s", o.ID))}
+	item["GSI1SK"] = &dynamodb.AttributeValue{S: aws.String("METADATA#")}
+
+	return item, nil
+}
+
+// orderLineItem turns li into the line item stored in its own partition, found on GSI1 next to its order.
+func orderLineItem(li OrderLineItem) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := dynamodbattribute.MarshalMap(&li)
+	if err != nil {
+		return nil, err
+	}
+	item["Type"] = &dynamodb.AttributeValue{S: aws.String("order_line_item")}
+	item["PK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("ORDERITEM#%s", li.ID))}
+	item["SK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("ORDER#%s", li.OrderID))}
+	item["GSI1PK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("ORDER#%s", li.OrderID))}
+	item["GSI1SK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("ORDERITEM#%s", li.ID))}
+
+	return item, nil
+}
+
+// purchaseKey is the marker telling the customer has ordered the product, see purchaseItem.
+func purchaseKey(customerID, productID SortableID) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {S: aws.String(fmt.Sprintf("USER#%s", customerID))},
+		"SK": {S: aws.String(fmt.Sprintf("PURCHASED#%s", productID))},
+	}
+}
+
+// purchaseItem is the update counting o in on the marker telling the customer of o has ordered the product.
+// Verifying a purchase reads the marker with a single GetItem, instead of going through the line items of every
+// ORDER# of the customer. The marker counts the orders including the product that haven't been cancelled or refunded,
+// see releasePurchaseItem, and points at the latest of them.
+func (db *DynamoDB) purchaseItem(o Order, productID SortableID) (*dynamodb.TransactWriteItem, error) {
+	purchased, err := dynamodbattribute.Marshal(o.CreatedDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName:        aws.String(db.tableName),
+			Key:              purchaseKey(o.CustomerID, productID),
+			UpdateExpression: aws.String("SET #Type = :type, #CustomerId = :customerId, #ProductId = :productId, #OrderId = :orderId, #PurchasedUtc = :purchased ADD #Orders :one"),
+			ExpressionAttributeNames: map[string]*string{
+				"#Type":         aws.String("Type"),
+				"#CustomerId":   aws.String("CustomerId"),
+				"#ProductId":    aws.String("ProductId"),
+				"#OrderId":      aws.String("OrderId"),
+				"#PurchasedUtc": aws.String("PurchasedUtc"),
+				"#Orders":       aws.String("Orders"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":type":       {S: aws.String("purchase")},
+				":customerId": {S: aws.String(o.CustomerID.String())},
+				":productId":  {S: aws.String(productID.String())},
+				":orderId":    {S: aws.String(o.ID.String())},
+				":purchased":  purchased,
+				":one":        {N: aws.String("1")},
+			},
+		},
+	}, nil
+}
+
+// releasePurchaseItem is the update counting a cancelled or refunded order off the marker of the product,
+// so the customer only counts as having ordered it while another order of it stands.
+func (db *DynamoDB) releasePurchaseItem(customerID, productID SortableID) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName:        aws.String(db.tableName),
+			Key:              purchaseKey(customerID, productID),
+			UpdateExpression: aws.String("ADD #Orders :minusOne"),
+			ExpressionAttributeNames: map[string]*string{
+				"#Orders": aws.String("Orders"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":minusOne": {N: aws.String("-1")},
+			},
+		},
+	}
+}
+
+// orderedProducts are the products of the line items of o, every one of them once.
+func orderedProducts(o Order) []SortableID {
+	var products []SortableID
+	seen := map[SortableID]bool{}
+	for _, li := range o.Items {
+		if li.ProductID != (SortableID{}) && !seen[li.ProductID] {
+			seen[li.ProductID] = true
+			products = append(products, li.ProductID)
+		}
+	}
+	return products
+}
+
+// orderStatusChangeItem is the put of a new entry in the audit history of an order.
+func (db *DynamoDB) orderStatusChangeItem(orderID SortableID, from, to OrderStatus, at time.Time) (*dynamodb.TransactWriteItem, error) {
+	change := OrderStatusChange{
+		ID:          db.newID(),
+		OrderID:     orderID,
+		From:        from,
+		To:          to,
+		ChangedDate: at,
+	}
+
+	item, err := dynamodbattribute.MarshalMap(&change)
+	if err != nil {
+		return nil, err
+	}
+	item["Type"] = &dynamodb.AttributeValue{S: aws.String("order_status")}
+	item["PK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("ORDER#%s", orderID))}
+	// The id only tells the second, the time in front keeps changes made within a second in order.
+	item["SK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("STATUS#%s#%s", sortableTime(at), change.ID))}
+
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName: aws.String(db.tableName),
+			Item:      item,
+		},
+	}, nil
+}
+
+// AddOrder places an order for a customer, starting its audit history in the same transaction.
+// The line items are stored as they are given, and the totals of the order are worked out from them.
+// Every line item needs a Quantity of at least 1 and a UnitPrice that isn't negative.
+func (db *DynamoDB) AddOrder(o Order) (Order, error) {
+	return db.placeOrder(o, nil, nil)
+}
+
+// placeOrder writes o with its line items, the start of its audit history, its outbox message
+// and the purchase markers of its products in a single transaction,
+// together with the extra writes, like emptying the basket the order was placed from.
+// When a coupon is given its discount is taken off before tax and shipping, and its usage is counted in the same transaction.
+func (db *DynamoDB) placeOrder(o Order, coupon *Coupon, extra []*dynamodb.TransactWriteItem) (Order, error) {
+	if o.CustomerID == (SortableID{}) {
+		return Order{}, errors.New("Expected CustomerID to have a value.")
+	}
+	// Every product gets a single marker, a transaction can't write the same item twice.
+	purchased := orderedProducts(o)
+
+	n := 3 + len(o.Items) + len(purchased) + len(extra) // The order, its history and its outbox message on top of the line items.
+	if coupon != nil {
+		n++
+	}
+	if n > transactWriteLimit {
+		return Order{}, fmt.Errorf("Expected the order to have at most %d writes, got %d.", transactWriteLimit, n)
+	}
+
+	o.ID = db.newID()
+	o.CreatedDate = db.now()
+	o.UpdatedDate = o.CreatedDate
+	o.Status = OrderPlaced
+
+	o.Subtotal = 0
+	for i := range o.Items {
+		li := &o.Items[i]
+		if li.Quantity < 1 {
+			return Order{}, errors.New("Expected Quantity of every line item to be at least 1.")
+		}
+		if li.UnitPrice < 0 {
+			return Order{}, errors.New("Expected UnitPrice of every line item not to be negative.")
+		}
+		li.ID = db.newID()
+		li.OrderID = o.ID
+		li.LineTotal = li.UnitPrice * li.Quantity
+		o.Subtotal += li.LineTotal
+	}
+	discount, tax, shipping, err := db.pricing.charges(o.Subtotal, coupon, o.CreatedDate)
+	if err != nil {
+		return Order{}, err
+	}
+	o.CouponCode = ""
+	if coupon != nil {
+		o.CouponCode = coupon.Code
+	}
+	o.Discount, o.Tax, o.Shipping = discount, tax, shipping
+	o.Total = o.Subtotal - o.Discount + o.Tax + o.Shipping
+
+	item, err := orderItem(o)
+	if err != nil {
+		return Order{}, err
+	}
+	history, err := db.orderStatusChangeItem(o.ID, "", OrderPlaced, o.CreatedDate)
+	if err != nil {
+		return Order{}, err
+	}
+
+	writes := []*dynamodb.TransactWriteItem{
+		{
+			Put: &dynamodb.Put{
+				TableName: aws.String(db.tableName),
+				Item:      item,
+			},
+		},
+		history,
+	}
+	for _, li := range o.Items {
+		item, err := orderLineItem(li)
+		if err != nil {
+			return Order{}, err
+		}
+		writes = append(writes, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName: aws.String(db.tableName),
+				Item:      item,
+			},
+		})
+	}
+
+	for _, productID := range purchased {
+		purchase, err := db.purchaseItem(o, productID)
+		if err != nil {
+			return Order{}, err
+		}
+		writes = append(writes, purchase)
+	}
+
+	placed, err := db.outboxItem(TopicOrderPlaced, o, o.CreatedDate)
+	if err != nil {
+		return Order{}, err
+	}
+	writes = append(writes, placed)
+
+	redeemed := -1
+	if coupon != nil {
+		redeemed = len(writes)
+		writes = append(writes, db.redeemCouponItem(*coupon, o.CreatedDate))
+	}
+
+	_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: append(writes, extra...),
+	})
+	if redeemed >= 0 && conditionFailedAt(err, redeemed) {
+		return Order{}, fmt.Errorf("%w: it expired or has been used up", ErrCouponNotApplicable)
+	}
+	for i := range extra {
+		if conditionFailedAt(err, len(writes)+i) {
+			return Order{}, ErrConflict
+		}
+	}
+	if err != nil {
+		return Order{}, err
+	}
+
+	return o, nil
+}
+
+// GetOrder fetches an order by its id, with its line items included.
+func (db *DynamoDB) GetOrder(orderID SortableID) (Order, error) {
+	// METADATA# sorts before every ORDERITEM#, so the order comes first.
+	res, err := db.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(db.tableName),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#GSI1PK = :gsi1pk"),
+		ExpressionAttributeNames: map[string]*string{
+			"#GSI1PK": aws.String("GSI1PK"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":gsi1pk": {
+				S: aws.String(fmt.Sprintf("ORDER#%s", orderID)),
+			},
+		},
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	if len(res.Items) == 0 || stringAttribute(res.Items[0], "GSI1SK") != "METADATA#" {
+		return Order{}, ErrNotFound
+	}
+
+	metadata, items := res.Items[0], res.Items[1:]
+
+	var o Order
+	err = dynamodbattribute.UnmarshalMap(metadata, &o)
+	if err != nil {
+		return Order{}, err
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &o.Items)
+	if err != nil {
+		return Order{}, err
+	}
+
+	return o, nil
+}
+
+// GetCustomerOrders fetches every order of a customer, newest first.
+func (db *DynamoDB) GetCustomerOrders(customerID SortableID) ([]Order, error) {
+	res, err := db.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(db.tableName),
+		KeyConditionExpression: aws.String("#PK = :pk And begins_with(#SK, :orders)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#PK": aws.String("PK"),
+			"#SK": aws.String("SK"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(fmt.Sprintf("USER#%s", customerID)),
+			},
+			":orders": {
+				S: aws.String("ORDER#"),
+			},
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []Order
+	err = dynamodbattribute.UnmarshalListOfMaps(res.Items, &orders)
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// GetCustomerOrdersBetween fetches the orders a customer placed from from to to, both included, newest first.
+// Orders are found by the time in their id, which is to the second.
+func (db *DynamoDB) GetCustomerOrdersBetween(customerID SortableID, from, to time.Time) ([]Order, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("Expected to (%s) to not be before from (%s).", to, from)
+	}
+
+	res, err := db.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(db.tableName),
+		KeyConditionExpression: aws.String("#PK = :pk And #SK Between :from And :to"),
+		ExpressionAttributeNames: map[string]*string{
+			"#PK": aws.String("PK"),
+			"#SK": aws.String("SK"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(fmt.Sprintf("USER#%s", customerID)),
+			},
+			":from": {
+				S: aws.String(fmt.Sprintf("ORDER#%s", MinIDAt(from))),
+			},
+			":to": {
+				S: aws.String(fmt.Sprintf("ORDER#%s", MaxIDAt(to))),
+			},
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []Order
+	err = dynamodbattribute.UnmarshalListOfMaps(res.Items, &orders)
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// TransitionOrder moves an order on to the status to, as long as the transition table allows it.
+// The update only goes through when the order still has the status it was read with,
+// and the change is written to the audit history and the outbox in the same transaction.
+// Cancelling or refunding an order counts it off the purchase markers of its products too, see purchaseItem.
+func (db *DynamoDB) TransitionOrder(orderID SortableID, to OrderStatus) (Order, error) {
+	o, err := db.GetOrder(orderID)
+	if err != nil {
+		return Order{}, err
+	}
+
+	if !o.Status.CanTransitionTo(to) {
+		return Order{}, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, o.Status, to)
+	}
+
+	from := o.Status
+	o.Status = to
+	o.UpdatedDate = db.now()
+
+	updated, err := dynamodbattribute.Marshal(o.UpdatedDate)
+	if err != nil {
+		return Order{}, err
+	}
+	history, err := db.orderStatusChangeItem(o.ID, from, to, o.UpdatedDate)
+	if err != nil {
+		return Order{}, err
+	}
+	changed, err := db.outboxItem(TopicOrderStatusChanged, OrderStatusChanged{
+		OrderID:    o.ID,
+		CustomerID: o.CustomerID,
+		From:       from,
+		To:         to,
+	}, o.UpdatedDate)
+	if err != nil {
+		return Order{}, err
+	}
+
+	writes := []*dynamodb.TransactWriteItem{
+		{
+			Update: &dynamodb.Update{
+				TableName: aws.String(db.tableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"PK": {
+						S: aws.String(fmt.Sprintf("USER#%s", o.CustomerID)),
+					},
+					"SK": {
+						S: aws.String(fmt.Sprintf("ORDER#%s", o.ID)),
+					},
+				},
+				ConditionExpression: aws.String("#Status = :from"),
+				UpdateExpression:    aws.String("SET #Status = :to, #UpdatedUtc = :updated"),
+				ExpressionAttributeNames: map[string]*string{
+					"#Status":     aws.String("Status"),
+					"#UpdatedUtc": aws.String("UpdatedUtc"),
+				},
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":from":    {S: aws.String(string(from))},
+					":to":      {S: aws.String(string(to))},
+					":updated": updated,
+				},
+			},
+		},
+		history,
+		changed,
+	}
+	if to == OrderCancelled || to == OrderRefunded {
+		for _, productID := range orderedProducts(o) {
+			writes = append(writes, db.releasePurchaseItem(o.CustomerID, productID))
+		}
+	}
+
+	_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: writes,
+	})
+	if conditionFailedAt(err, 0) {
+		return Order{}, ErrConflict
+	}
+	if err != nil {
+		return Order{}, err
+	}
+
+	return o, nil
+}
+
+// GetOrderHistory fetches the audit history of an order, oldest change first.
+func (db *DynamoDB) GetOrderHistory(orderID SortableID) ([]OrderStatusChange, error) {
+	items, err := db.queryPartition(fmt.Sprintf("ORDER#%s", orderID))
+	if err != nil {
+		return nil, err
+	}
+
+	var history []OrderStatusChange
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &history)
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
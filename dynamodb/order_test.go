@@ -0,0 +1,139 @@
+package dynamodb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestOrderStatusCanTransitionTo(t *testing.T) {
+	is := is.New(t)
+
+	is.True(OrderPlaced.CanTransitionTo(OrderPaid))
+	is.True(OrderPaid.CanTransitionTo(OrderShipped))
+	is.True(OrderDelivered.CanTransitionTo(OrderRefunded))
+
+	is.True(!OrderPlaced.CanTransitionTo(OrderShipped))  // It has to be paid first.
+	is.True(!OrderCancelled.CanTransitionTo(OrderPaid))  // Cancelled orders are done.
+	is.True(!OrderShipped.CanTransitionTo(OrderShipped)) // Nothing happened.
+}
+
+func TestAddOrder(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	o, err := tdb.AddOrder(Order{CustomerID: customerID})
+	is.NoErr(err)
+	is.Equal(o.Status, OrderPlaced)
+
+	fetched, err := tdb.GetOrder(o.ID)
+	is.NoErr(err)
+	is.Equal(fetched.CustomerID, customerID)
+
+	orders, err := tdb.GetCustomerOrders(customerID)
+	is.NoErr(err)
+	is.True(len(orders) == 1)
+
+	history, err := tdb.GetOrderHistory(o.ID)
+	is.NoErr(err)
+	is.True(len(history) == 1)
+	is.Equal(history[0].To, OrderPlaced)
+}
+
+func TestAddOrderValidation(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	_, err = tdb.AddOrder(Order{CustomerID: customerID, Items: []OrderLineItem{{ProductID: NewSortableID(), UnitPrice: -100, Quantity: 1}}})
+	is.True(err != nil) // Prices can't be negative.
+
+	_, err = tdb.AddOrder(Order{CustomerID: customerID, Items: []OrderLineItem{{ProductID: NewSortableID(), UnitPrice: 100}}})
+	is.True(err != nil) // Nothing is ordered.
+
+	orders, err := tdb.GetCustomerOrders(customerID)
+	is.NoErr(err)
+	is.True(len(orders) == 0)
+}
+
+func TestTransitionOrder(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	o, err := tdb.AddOrder(Order{CustomerID: NewSortableID()})
+	is.NoErr(err)
+
+	for _, to := range []OrderStatus{OrderPaid, OrderShipped, OrderDelivered} {
+		o, err = tdb.TransitionOrder(o.ID, to)
+		is.NoErr(err)
+		is.Equal(o.Status, to)
+	}
+
+	_, err = tdb.TransitionOrder(o.ID, OrderCancelled)
+	is.True(errors.Is(err, ErrInvalidTransition)) // Delivered orders can't be cancelled.
+
+	fetched, err := tdb.GetOrder(o.ID)
+	is.NoErr(err)
+	is.Equal(fetched.Status, OrderDelivered)
+
+	history, err := tdb.GetOrderHistory(o.ID)
+	is.NoErr(err)
+	is.True(len(history) == 4) // Placed, paid, shipped and delivered.
+	is.Equal(history[3].From, OrderShipped)
+	is.Equal(history[3].To, OrderDelivered)
+}
+
+func TestTransitionOrderNotFound(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	_, err = tdb.TransitionOrder(NewSortableID(), OrderPaid)
+	is.Equal(err, ErrNotFound)
+}
+
+func TestGetCustomerOrdersBetween(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	now := time.Date(2020, 9, 14, 9, 0, 0, 0, time.UTC)
+	tdb, err := NewTestDynamoDB(WithClock(func() time.Time { return now }))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	_, err = tdb.AddOrder(Order{CustomerID: customerID}) // Two weeks ago.
+	is.NoErr(err)
+	now = now.AddDate(0, 0, 7)
+	lastWeek, err := tdb.AddOrder(Order{CustomerID: customerID})
+	is.NoErr(err)
+	now = now.AddDate(0, 0, 7)
+	_, err = tdb.AddOrder(Order{CustomerID: customerID})
+	is.NoErr(err)
+
+	orders, err := tdb.GetCustomerOrdersBetween(customerID, now.AddDate(0, 0, -7), now.Add(-time.Second))
+	is.NoErr(err)
+	is.Equal(len(orders), 1)
+	is.Equal(orders[0].ID, lastWeek.ID)
+
+	orders, err = tdb.GetCustomerOrdersBetween(customerID, now.AddDate(0, 0, -7), now)
+	is.NoErr(err)
+	is.Equal(len(orders), 2)
+	is.True(orders[0].CreatedDate.After(orders[1].CreatedDate)) // Newest first.
+
+	_, err = tdb.GetCustomerOrdersBetween(customerID, now, now.Add(-time.Hour))
+	is.True(err != nil)
+}
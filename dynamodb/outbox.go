@@ -0,0 +1,297 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// The topics of the messages written to the outbox.
+const (
+	TopicOrderPlaced        = "order.placed"
+	TopicOrderStatusChanged = "order.status_changed"
+	TopicStockLow           = "stock.low"
+)
+
+// unpublishedPK is the GSI2 partition every message waiting to be published lives in.
+// Published messages don't have GSI2 attributes, which keeps the index sparse.
+const unpublishedPK = "OUTBOX#UNPUBLISHED"
+
+// deadLetterPK is the GSI2 partition of the messages that failed to publish too many times, see WithOutboxMaxAttempts.
+const deadLetterPK = "OUTBOX#DEADLETTER"
+
+// publishedOutboxTTL is how long published messages are kept around before DynamoDB expires them.
+const publishedOutboxTTL = 7 * 24 * time.Hour
+
+// OutboxMessage is an integration event for other systems, written in the same transaction as the change it's about.
+// Messages are published at least once, consumers deduplicate them by ID.
+type OutboxMessage struct {
+	ID            SortableID      `json:"id" dynamodbav:"Id,omitempty"`
+	Topic         string          `json:"topic" dynamodbav:"Topic,omitempty"`
+	Payload       json.RawMessage `json:"payload" dynamodbav:"-"`
+	CreatedDate   time.Time       `json:"createdUtc" dynamodbav:"CreatedUtc,omitempty"`
+	PublishedDate *time.Time      `json:"publishedUtc,omitempty" dynamodbav:"PublishedUtc,omitempty"`
+	Attempts      int             `json:"attempts" dynamodbav:"Attempts,omitempty"`
+}
+
+// StockLow is the payload of TopicStockLow.
+type StockLow struct {
+	ProductID SortableID `json:"productId"`
+	OptionID  SortableID `json:"optionId"`
+	Stock     int        `json:"stock"`
+}
+
+// OrderStatusChanged is the payload of TopicOrderStatusChanged.
+type OrderStatusChanged struct {
+	OrderID    SortableID  `json:"orderId"`
+	CustomerID SortableID  `json:"customerId"`
+	From       OrderStatus `json:"from"`
+	To         OrderStatus `json:"to"`
+}
+
+// Publisher sends outbox messages on to other systems, like a queue or an event bus.
+type Publisher interface {
+	Publish(ctx context.Context, m OutboxMessage) error
+}
+
+// MemoryPublisher keeps the messages it publishes in memory, for tests and running locally.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []OutboxMessage
+}
+
+// Publish satisfies the Publisher interface.
+func (p *MemoryPublisher) Publish(ctx context.Context, m OutboxMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, m)
+	return nil
+}
+
+// Messages returns the messages published so far, oldest first.
+func (p *MemoryPublisher) Messages() []OutboxMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]OutboxMessage(nil), p.messages...)
+}
+
+// outboxItem is the put of a new message in the outbox, to add to the transaction of the change it's about.
+func (db *DynamoDB) outboxItem(topic string, payload interface{}, at time.Time) (*dynamodb.TransactWriteItem, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	m := OutboxMessage{
+		ID:          db.newID(),
+		Topic:       topic,
+		CreatedDate: at,
+	}
+	item, err := dynamodbattribute.MarshalMap(&m)
+	if err != nil {
+		return nil, err
+	}
+	item["Type"] = &dynamodb.AttributeValue{S: aws.String("outbox_message")}
+	for name, av := range outboxKey(m.ID) {
+		item[name] = av
+	}
+	item["GSI2PK"] = &dynamodb.AttributeValue{S: aws.String(unpublishedPK)}
+	// The id only tells the second, the time in front keeps messages written within a second in order.
+	item["GSI2SK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("OUTBOX#%s#%s", sortableTime(at), m.ID))}
+	item["Payload"] = &dynamodb.AttributeValue{S: aws.String(string(b))}
+
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName: aws.String(db.tableName),
+			Item:      item,
+		},
+	}, nil
+}
+
+func decodeOutboxMessage(item map[string]*dynamodb.AttributeValue) (OutboxMessage, error) {
+	var m OutboxMessage
+	if err := dynamodbattribute.UnmarshalMap(item, &m); err != nil {
+		return OutboxMessage{}, err
+	}
+	m.Payload = json.RawMessage(stringAttribute(item, "Payload"))
+	return m, nil
+}
+
+// RelayOutbox publishes up to limit unpublished messages, oldest first, and marks them published.
+// It stops at the first message failing to publish, so messages go out in the order they were written, to the nanosecond;
+// the failure is counted on the message and it's retried by the next relay.
+// A message that failed as many times as WithOutboxMaxAttempts allows is moved to the dead letters instead,
+// and the relay goes on with the next one, so a single bad message doesn't hold up the rest.
+// A message can get published twice when the relay dies between publishing and marking it, never zero times.
+// It returns how many messages got published.
+func (db *DynamoDB) RelayOutbox(ctx context.Context, p Publisher, limit int) (int, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	messages, err := db.queryOutbox(ctx, unpublishedPK, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var published int
+	for _, m := range messages {
+		if err := p.Publish(ctx, m); err != nil {
+			attempts, markErr := db.markOutboxAttempt(ctx, m.ID)
+			if markErr != nil {
+				return published, markErr
+			}
+			if attempts < db.outboxMaxAttempts {
+				return published, fmt.Errorf("publishing %s %s: %w", m.Topic, m.ID, err)
+			}
+			if err := db.markOutboxDeadLetter(ctx, m.ID); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		if err := db.markOutboxPublished(ctx, m.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+// GetDeadLetterMessages fetches up to limit messages RelayOutbox gave up on publishing, oldest first.
+func (db *DynamoDB) GetDeadLetterMessages(ctx context.Context, limit int) ([]OutboxMessage, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	return db.queryOutbox(ctx, deadLetterPK, limit)
+}
+
+// queryOutbox fetches up to limit messages from the GSI2 partition pk, oldest first.
+func (db *DynamoDB) queryOutbox(ctx context.Context, pk string, limit int) ([]OutboxMessage, error) {
+	res, err := db.db.QueryWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(db.tableName),
+		IndexName:              aws.String("GSI2"),
+		KeyConditionExpression: aws.String("#GSI2PK = :gsi2pk"),
+		ExpressionAttributeNames: map[string]*string{
+			"#GSI2PK": aws.String("GSI2PK"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":gsi2pk": {
+				S: aws.String(pk),
+			},
+		},
+		ScanIndexForward: aws.Bool(true),
+		Limit:            aws.Int64(int64(limit)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]OutboxMessage, 0, len(res.Items))
+	for _, item := range res.Items {
+		m, err := decodeOutboxMessage(item)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+// markOutboxPublished takes a message out of the unpublished index, and lets DynamoDB expire it after a while.
+// Another relay marking it first is fine, it's published either way.
+func (db *DynamoDB) markOutboxPublished(ctx context.Context, id SortableID) error {
+	now := db.now()
+	published, err := dynamodbattribute.Marshal(now)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(db.tableName),
+		Key:                 outboxKey(id),
+		ConditionExpression: aws.String("attribute_exists(#GSI2PK)"),
+		UpdateExpression:    aws.String("SET #PublishedUtc = :published, #ExpiresAt = :expiresAt REMOVE #GSI2PK, #GSI2SK"),
+		ExpressionAttributeNames: map[string]*string{
+			"#PublishedUtc": aws.String("PublishedUtc"),
+			"#ExpiresAt":    aws.String(ttlAttribute),
+			"#GSI2PK":       aws.String("GSI2PK"),
+			"#GSI2SK":       aws.String("GSI2SK"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":published": published,
+			":expiresAt": {N: aws.String(fmt.Sprint(now.Add(publishedOutboxTTL).Unix()))},
+		},
+	})
+	if isConditionFailed(err) {
+		return nil
+	}
+
+	return err
+}
+
+// markOutboxAttempt counts a failed attempt to publish a message, returning how many attempts failed so far.
+func (db *DynamoDB) markOutboxAttempt(ctx context.Context, id SortableID) (int, error) {
+	res, err := db.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(db.tableName),
+		Key:              outboxKey(id),
+		UpdateExpression: aws.String("ADD #Attempts :one"),
+		ExpressionAttributeNames: map[string]*string{
+			"#Attempts": aws.String("Attempts"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one": {N: aws.String("1")},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueUpdatedNew),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var m OutboxMessage
+	if err := dynamodbattribute.UnmarshalMap(res.Attributes, &m); err != nil {
+		return 0, err
+	}
+
+	return m.Attempts, nil
+}
+
+// markOutboxDeadLetter moves a message from the unpublished index to the dead letters, keeping its place in line.
+// Another relay moving or publishing it first is fine, it's out of the unpublished index either way.
+func (db *DynamoDB) markOutboxDeadLetter(ctx context.Context, id SortableID) error {
+	_, err := db.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(db.tableName),
+		Key:                 outboxKey(id),
+		ConditionExpression: aws.String("#GSI2PK = :unpublished"),
+		UpdateExpression:    aws.String("SET #GSI2PK = :deadLetter"),
+		ExpressionAttributeNames: map[string]*string{
+			"#GSI2PK": aws.String("GSI2PK"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":unpublished": {S: aws.String(unpublishedPK)},
+			":deadLetter":  {S: aws.String(deadLetterPK)},
+		},
+	})
+	if isConditionFailed(err) {
+		return nil
+	}
+
+	return err
+}
+
+// outboxKey is the key of the message with id.
+func outboxKey(id SortableID) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {S: aws.String(fmt.Sprintf("OUTBOX#%s", id))},
+		"SK": {S: aws.String("METADATA#")},
+	}
+}
@@ -0,0 +1,150 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+type failingPublisher struct{}
+
+func (failingPublisher) Publish(ctx context.Context, m OutboxMessage) error {
+	return errors.New("queue is down")
+}
+
+// topicFailingPublisher fails to publish the messages of topic, and publishes the others to MemoryPublisher.
+type topicFailingPublisher struct {
+	MemoryPublisher
+	topic string
+}
+
+func (p *topicFailingPublisher) Publish(ctx context.Context, m OutboxMessage) error {
+	if m.Topic == p.topic {
+		return errors.New("the queue refuses the message")
+	}
+	return p.MemoryPublisher.Publish(ctx, m)
+}
+
+func TestMemoryPublisher(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	var p MemoryPublisher
+	is.NoErr(p.Publish(ctx, OutboxMessage{Topic: TopicStockLow}))
+	is.NoErr(p.Publish(ctx, OutboxMessage{Topic: TopicOrderPlaced}))
+
+	messages := p.Messages()
+	is.Equal(len(messages), 2)
+	is.Equal(messages[0].Topic, TopicStockLow)
+	is.Equal(messages[1].Topic, TopicOrderPlaced)
+}
+
+func TestRelayOutbox(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	tdb, err := NewTestDynamoDB(WithLowStockThreshold(3))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	o, err := tdb.AddOrder(Order{CustomerID: NewSortableID()})
+	is.NoErr(err)
+	_, err = tdb.TransitionOrder(o.ID, OrderPaid)
+	is.NoErr(err)
+
+	p, err := tdb.AddProduct(Product{Name: "Tees", Category: "Accessories"})
+	is.NoErr(err)
+	option, err := tdb.AddOptionToProduct(p.ID, Option{Color: "White", Stock: 10})
+	is.NoErr(err)
+	_, err = tdb.SetOptionStock(p.ID, option.ID, 8) // Not low, nothing to tell.
+	is.NoErr(err)
+	_, err = tdb.SetOptionStock(p.ID, option.ID, 2)
+	is.NoErr(err)
+
+	var publisher MemoryPublisher
+	n, err := tdb.RelayOutbox(ctx, &publisher, 0)
+	is.NoErr(err)
+	is.Equal(n, 3)
+
+	messages := publisher.Messages()
+	is.Equal(len(messages), 3)
+	is.Equal(messages[0].Topic, TopicOrderPlaced)
+	is.Equal(messages[1].Topic, TopicOrderStatusChanged)
+	is.Equal(messages[2].Topic, TopicStockLow)
+
+	var changed OrderStatusChanged
+	is.NoErr(json.Unmarshal(messages[1].Payload, &changed))
+	is.Equal(changed.OrderID, o.ID)
+	is.Equal(changed.To, OrderPaid)
+
+	var low StockLow
+	is.NoErr(json.Unmarshal(messages[2].Payload, &low))
+	is.Equal(low.OptionID, option.ID)
+	is.Equal(low.Stock, 2)
+
+	// Published messages aren't relayed again.
+	n, err = tdb.RelayOutbox(ctx, &publisher, 0)
+	is.NoErr(err)
+	is.Equal(n, 0)
+	is.Equal(len(publisher.Messages()), 3)
+}
+
+func TestRelayOutboxFailingPublisher(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	_, err = tdb.AddOrder(Order{CustomerID: NewSortableID()})
+	is.NoErr(err)
+
+	n, err := tdb.RelayOutbox(ctx, failingPublisher{}, 0)
+	is.True(err != nil)
+	is.Equal(n, 0)
+
+	// The message is still waiting, with the failed attempt counted.
+	var publisher MemoryPublisher
+	n, err = tdb.RelayOutbox(ctx, &publisher, 0)
+	is.NoErr(err)
+	is.Equal(n, 1)
+	is.Equal(publisher.Messages()[0].Attempts, 1)
+}
+
+func TestRelayOutboxDeadLetter(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	tdb, err := NewTestDynamoDB(WithOutboxMaxAttempts(2))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	o, err := tdb.AddOrder(Order{CustomerID: NewSortableID()})
+	is.NoErr(err)
+	_, err = tdb.TransitionOrder(o.ID, OrderPaid)
+	is.NoErr(err)
+
+	publisher := &topicFailingPublisher{topic: TopicOrderPlaced}
+	n, err := tdb.RelayOutbox(ctx, publisher, 0)
+	is.True(err != nil) // The first attempt holds up the messages after it.
+	is.Equal(n, 0)
+
+	n, err = tdb.RelayOutbox(ctx, publisher, 0)
+	is.NoErr(err) // The second attempt gives up on it.
+	is.Equal(n, 1)
+	is.Equal(publisher.Messages()[0].Topic, TopicOrderStatusChanged)
+
+	dead, err := tdb.GetDeadLetterMessages(ctx, 0)
+	is.NoErr(err)
+	is.Equal(len(dead), 1)
+	is.Equal(dead[0].Topic, TopicOrderPlaced)
+	is.Equal(dead[0].Attempts, 2)
+
+	n, err = tdb.RelayOutbox(ctx, publisher, 0)
+	is.NoErr(err)
+	is.Equal(n, 0) // Dead letters aren't relayed again.
+}
@@ -0,0 +1,61 @@
+package dynamodb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// PaginationKey is an opaque cursor pointing at where the previous page of a query stopped.
+// It's safe to hand out to clients, pass it back to continue from that point.
+type PaginationKey string
+
+// UnmarshalDynamoDBAttributeValue satisfy the dynamodbattribute.Unmarshaler interface.
+// It turns a LastEvaluatedKey, whatever index it belongs to, into a PaginationKey.
+func (k *PaginationKey) UnmarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	if av.M == nil {
+		return nil
+	}
+
+	key := make(map[string]string, len(av.M))
+	for name, v := range av.M {
+		if v.S == nil {
+			return fmt.Errorf("pagination key attribute %s is not a string", name)
+		}
+		key[name] = *v.S
+	}
+
+	b, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	*k = PaginationKey(base64.URLEncoding.EncodeToString(b))
+
+	return nil
+}
+
+func (k PaginationKey) decode() (map[string]*dynamodb.AttributeValue, error) {
+	if k == "" {
+		return nil, nil
+	}
+
+	b, err := base64.URLEncoding.DecodeString(string(k))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPaginationKey, err)
+	}
+
+	var key map[string]string
+	if err := json.Unmarshal(b, &key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPaginationKey, err)
+	}
+
+	attrs := make(map[string]*dynamodb.AttributeValue, len(key))
+	for name, v := range key {
+		attrs[name] = &dynamodb.AttributeValue{S: aws.String(v)}
+	}
+
+	return attrs, nil
+}
@@ -0,0 +1,50 @@
+package dynamodb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/matryer/is"
+)
+
+func TestPaginationKeyRoundTrip(t *testing.T) {
+	is := is.New(t)
+	lastEvaluatedKey := map[string]*dynamodb.AttributeValue{
+		"PK":     {S: aws.String("PRODUCT#1")},
+		"SK":     {S: aws.String("OPTION#2")},
+		"GSI2PK": {S: aws.String(lowStockPK)},
+		"GSI2SK": {S: aws.String("STOCK#000000000000001#OPTION#2")},
+	}
+
+	var key PaginationKey
+	err := dynamodbattribute.UnmarshalMap(lastEvaluatedKey, &key)
+	is.NoErr(err)
+	is.True(key != "")
+
+	decoded, err := key.decode()
+	is.NoErr(err)
+	is.Equal(decoded, lastEvaluatedKey)
+}
+
+func TestPaginationKeyEmpty(t *testing.T) {
+	is := is.New(t)
+
+	var key PaginationKey
+	err := dynamodbattribute.UnmarshalMap(nil, &key)
+	is.NoErr(err)
+	is.Equal(key, PaginationKey(""))
+
+	decoded, err := key.decode()
+	is.NoErr(err)
+	is.True(decoded == nil)
+}
+
+func TestPaginationKeyMalformed(t *testing.T) {
+	is := is.New(t)
+
+	_, err := PaginationKey("not a key").decode()
+	is.True(errors.Is(err, ErrInvalidPaginationKey))
+}
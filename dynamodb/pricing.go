@@ -0,0 +1,40 @@
+package dynamodb
+
+import "time"
+
+// Pricing is how the tax and shipping of an order are worked out.
+// Amounts are in the smallest unit of the currency, like the prices of the products.
+type Pricing struct {
+	TaxPercent       int // Added on top of the subtotal.
+	ShippingFee      int
+	FreeShippingFrom int // The subtotal from which shipping is free, 0 means shipping is never free.
+}
+
+// tax is the tax charged on subtotal, rounded to the nearest unit.
+func (p Pricing) tax(subtotal int) int {
+	return (subtotal*p.TaxPercent + 50) / 100
+}
+
+// shipping is the shipping fee charged for an order of subtotal.
+func (p Pricing) shipping(subtotal int) int {
+	if p.FreeShippingFrom > 0 && subtotal >= p.FreeShippingFrom {
+		return 0
+	}
+	return p.ShippingFee
+}
+
+// charges works out what is charged on top of, or taken off, a subtotal, with the coupon if there is one.
+// The discount is taken off before tax and shipping are worked out.
+func (p Pricing) charges(subtotal int, coupon *Coupon, now time.Time) (discount, tax, shipping int, err error) {
+	if coupon != nil {
+		if err := coupon.usable(now); err != nil {
+			return 0, 0, 0, err
+		}
+		if discount, err = coupon.discount(subtotal); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+
+	discounted := subtotal - discount
+	return discount, p.tax(discounted), p.shipping(discounted), nil
+}
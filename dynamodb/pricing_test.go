@@ -0,0 +1,21 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestPricing(t *testing.T) {
+	is := is.New(t)
+	pricing := Pricing{
+		TaxPercent:       25,
+		ShippingFee:      49,
+		FreeShippingFrom: 500,
+	}
+
+	is.Equal(pricing.tax(398), 100) // 99.5 rounds up.
+	is.Equal(pricing.shipping(499), 49)
+	is.Equal(pricing.shipping(500), 0)
+	is.Equal(Pricing{ShippingFee: 49}.shipping(10000), 49) // Never free.
+}
@@ -1,11 +1,10 @@
 package dynamodb
 
 import (
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"math"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -17,12 +16,17 @@ import (
 // For example, a product can have many different colors, sizes etc etc.
 type Option struct {
 	ID             SortableID `json:"id" dynamodbav:"Id,omitempty"`
+	ProductID      SortableID `json:"productId" dynamodbav:"ProductId,omitempty"`
 	CreatedDate    time.Time  `json:"createdUtc" dynamodbav:"CreatedUtc,omitempty"`
 	Size           string     `json:"size" dynamodbav:"Size,omitempty"`     // TODO enum?
 	Socket         string     `json:"socket" dynamodbav:"Socket,omitempty"` // TODO enum?
 	Color          string     `json:"color" dynamodbav:"Color,omitempty"`   // TODO enum?
 	Stock          int        `json:"stock" dynamodbav:"Stock,omitempty"`
 	ShaftStiffness float64    `json:"shaftStiffness" dynamodbav:"ShaftStiffness,omitempty"`
+
+	// Archived options stay with their product, but can't be ordered, see ArchiveOption.
+	Archived     bool       `json:"archived" dynamodbav:"Archived,omitempty"`
+	ArchivedDate *time.Time `json:"archivedUtc,omitempty" dynamodbav:"ArchivedUtc,omitempty"`
 }
 
 // Product represents the product that customers buys.
@@ -38,76 +42,271 @@ type Product struct {
 	Weight      int        `json:"weight" dynamodbav:"Weight,omitempty"`
 	Sale        int        `json:"sale" dynamodbav:"Sale,omitempty"`
 	Options     []Option   `json:"options" dynamodbav:"-"`
+
+	// Version counts the changes to the product, starting at 1. UpdateProduct keeps the versions before it.
+	Version     int        `json:"version" dynamodbav:"Version,omitempty"`
+	UpdatedDate *time.Time `json:"updatedUtc,omitempty" dynamodbav:"UpdatedUtc,omitempty"`
+	UpdatedBy   string     `json:"updatedBy,omitempty" dynamodbav:"UpdatedBy,omitempty"`
+
+	// Archived products are kept with their options, but aren't listed, searched or ordered, see ArchiveProduct.
+	Archived     bool       `json:"archived" dynamodbav:"Archived,omitempty"`
+	ArchivedDate *time.Time `json:"archivedUtc,omitempty" dynamodbav:"ArchivedUtc,omitempty"`
+
+	// The ratings are maintained by AddReview and DeleteReview, AddProduct starts them at zero.
+	RatingCount     int             `json:"ratingCount" dynamodbav:"RatingCount,omitempty"`
+	RatingSum       int             `json:"-" dynamodbav:"RatingSum,omitempty"`
+	RatingHistogram RatingHistogram `json:"ratingHistogram" dynamodbav:"-"`
+	AverageRating   float64         `json:"averageRating" dynamodbav:"-"`
+}
+
+// EffectivePrice is what the product sells for right now, Sale when it's a discount on Price.
+func (p Product) EffectivePrice() int {
+	if p.Sale > 0 && p.Sale < p.Price {
+		return p.Sale
+	}
+	return p.Price
+}
+
+// RatingHistogram counts the reviews per rating, index 0 holding the 1 star reviews.
+type RatingHistogram [5]int
+
+// ratingBucket is the top level attribute counting the reviews with the given rating.
+// DynamoDB can only ADD to top level attributes, that's why the histogram isn't a list or a map.
+func ratingBucket(rating int) string {
+	return fmt.Sprintf("Rating%dCount", rating)
+}
+
+// UnmarshalDynamoDBAttributeValue satisfy the dynamodbattribute.Unmarshaler interface.
+// On top of the regular attributes it collects the rating histogram and works out the average rating.
+func (p *Product) UnmarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	type product Product // Drops the methods of Product, so decoding it doesn't end up here again.
+	if err := dynamodbattribute.Unmarshal(av, (*product)(p)); err != nil || av == nil {
+		return err
+	}
+
+	for rating := 1; rating <= len(p.RatingHistogram); rating++ {
+		bucket, ok := av.M[ratingBucket(rating)]
+		if !ok || bucket.N == nil {
+			continue
+		}
+		n, err := strconv.Atoi(*bucket.N)
+		if err != nil {
+			return err
+		}
+		p.RatingHistogram[rating-1] = n
+	}
+
+	if p.RatingCount > 0 {
+		p.AverageRating = float64(p.RatingSum) / float64(p.RatingCount)
+	}
+
+	return nil
 }
 
 // AddProduct take a Product p and attempts to put that item into DynamoDB.
-func (db *DynamoDB) AddProduct(p Product) (Product, error) {
+// The ID and CreatedDate of p are kept when set, like when importing products from elsewhere,
+// and a product with the same ID existing already fails with ErrConflict.
+// With WithIdempotencyKey a retried call returns the product added the first time instead of adding it again.
+func (db *DynamoDB) AddProduct(p Product, opts ...WriteOption) (Product, error) {
+	o, err := newWriteOptions(opts)
+	if err != nil {
+		return Product{}, err
+	}
+	request := p
+	if err := validateProductTerms(p); err != nil {
+		return Product{}, err
+	}
+
+	if p.ID == (SortableID{}) {
+		p.ID = db.newID()
+	}
+	if p.CreatedDate.IsZero() {
+		p.CreatedDate = db.now()
+	}
+	p = p.withoutMaintainedFields()
+
+	item, err := productItem(p)
+	if err != nil {
+		return Product{}, err
+	}
+
+	// The search tokens are written together with the product, so it can't be added without being searchable.
+	var tokens []*dynamodb.TransactWriteItem
+	if !p.Archived {
+		tokens = db.indexWrites(p)
+	}
+
+	if _, err := db.putOnce(o, "AddProduct", request, item, &p, tokens...); err != nil {
+		return Product{}, err
+	}
+
+	return p, nil
+}
+
+// withoutMaintainedFields resets what the store keeps track of by itself, the ratings and the version history,
+// so a new product can't come with them made up.
+func (p Product) withoutMaintainedFields() Product {
+	p.RatingCount = 0
+	p.RatingSum = 0
+	p.RatingHistogram = RatingHistogram{}
+	p.AverageRating = 0
+	p.Version = 1
+	p.UpdatedBy = ""
+	p.UpdatedDate = nil
+	return p
+}
+
+// productKey is the key of the METADATA# item of the product with id.
+func productKey(id SortableID) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {
+			S: aws.String(fmt.Sprintf("PRODUCT#%s", id)),
+		},
+		"SK": {
+			S: aws.String("METADATA#"),
+		},
+	}
+}
 
-	p.CreatedDate = time.Now()
-	p.ID = NewSortableID()
+// optionKey is the key of an option, in the partition of its product.
+func optionKey(productID, optionID SortableID) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {
+			S: aws.String(fmt.Sprintf("PRODUCT#%s", productID)),
+		},
+		"SK": {
+			S: aws.String(fmt.Sprintf("OPTION#%s", optionID)),
+		},
+	}
+}
 
+// productItem turns p into the METADATA# item stored in DynamoDB.
+// Archived products are left out of the category index.
+func productItem(p Product) (map[string]*dynamodb.AttributeValue, error) {
 	pk := fmt.Sprintf("PRODUCT#%s", p.ID)
 	sort := "METADATA#"
-	gs1pk := fmt.Sprintf("PRODUCT#CATEGORY#%s", p.Category)
-	gs1sk := zerosPricePadding(p.Price)
 
 	item, err := dynamodbattribute.MarshalMap(&p)
 	if err != nil {
-		return Product{}, err
+		return nil, err
 	}
 
 	item["Type"] = &dynamodb.AttributeValue{S: aws.String("product")}
 	item["PK"] = &dynamodb.AttributeValue{S: aws.String(pk)}
 	item["SK"] = &dynamodb.AttributeValue{S: aws.String(sort)}
-	item["GSI1PK"] = &dynamodb.AttributeValue{S: aws.String(gs1pk)}
-	item["GSI1SK"] = &dynamodb.AttributeValue{S: aws.String(gs1sk)}
+	if !p.Archived {
+		item["GSI1PK"] = &dynamodb.AttributeValue{S: aws.String(categoryPK(p.Category))}
+		item["GSI1SK"] = &dynamodb.AttributeValue{S: aws.String(zerosPadding(p.Price))}
+	}
 
-	_, err = db.db.PutItem(&dynamodb.PutItemInput{
-		TableName: aws.String(db.tableName),
-		Item:      item,
-	})
+	return item, nil
+}
 
-	return p, err
+// categoryPK is the GSI1 partition listing the products of category.
+func categoryPK(category string) string {
+	return fmt.Sprintf("PRODUCT#CATEGORY#%s", category)
 }
 
-// AddOptionToProduct adds a single option to a product.
+// AddOptionToProduct adds a single option to a product, the product not existing fails with ErrNotFound.
+// The ID and CreatedDate of option are kept when set, and an option with the same ID existing already fails with ErrConflict.
+// A negative Stock fails with ErrNegativeStock.
 func (db *DynamoDB) AddOptionToProduct(id SortableID, option Option) (Option, error) {
-	option.ID = NewSortableID()
-	option.CreatedDate = time.Now()
+	if option.Stock < 0 {
+		return Option{}, ErrNegativeStock
+	}
+	if option.ID == (SortableID{}) {
+		option.ID = db.newID()
+	}
+	if option.CreatedDate.IsZero() {
+		option.CreatedDate = db.now()
+	}
+	option.ProductID = id
+
+	item, err := db.optionItem(option)
+	if err != nil {
+		return Option{}, err
+	}
+
+	_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				ConditionCheck: &dynamodb.ConditionCheck{
+					TableName:           aws.String(db.tableName),
+					Key:                 productKey(id),
+					ConditionExpression: aws.String("attribute_exists(#PK)"),
+					ExpressionAttributeNames: map[string]*string{
+						"#PK": aws.String("PK"),
+					},
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(db.tableName),
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(#PK)"),
+					ExpressionAttributeNames: map[string]*string{
+						"#PK": aws.String("PK"),
+					},
+				},
+			},
+		},
+	})
+	if conditionFailedAt(err, 0) {
+		return Option{}, ErrNotFound
+	}
+	if conditionFailedAt(err, 1) {
+		return Option{}, ErrConflict
+	}
+	if err != nil {
+		return Option{}, err
+	}
 
-	pk := fmt.Sprintf("PRODUCT#%s", id)
+	return option, nil
+}
+
+// optionItem turns option into the OPTION# item stored under its product.
+func (db *DynamoDB) optionItem(option Option) (map[string]*dynamodb.AttributeValue, error) {
+	pk := fmt.Sprintf("PRODUCT#%s", option.ProductID)
 	sort := fmt.Sprintf("OPTION#%s", option.ID)
 
 	item, err := dynamodbattribute.MarshalMap(&option)
 	if err != nil {
-		return Option{}, err
+		return nil, err
 	}
 	item["Type"] = &dynamodb.AttributeValue{S: aws.String("product_option")}
 	item["PK"] = &dynamodb.AttributeValue{S: aws.String(pk)}
 	item["SK"] = &dynamodb.AttributeValue{S: aws.String(sort)}
+	if db.isLowStock(option.Stock) {
+		item["GSI2PK"] = &dynamodb.AttributeValue{S: aws.String(lowStockPK)}
+		item["GSI2SK"] = &dynamodb.AttributeValue{S: aws.String(lowStockSK(option.Stock, option.ID))}
+	}
 
-	_, err = db.db.PutItem(&dynamodb.PutItemInput{
-		TableName: aws.String(db.tableName),
-		Item:      item,
-	})
-
-	return option, err
+	return item, nil
 }
 
 // GetProduct fetches the product will all their options included.
 func (db *DynamoDB) GetProduct(id SortableID) (Product, error) {
 	var result Product
 
+	// The product partition holds more than the product, like its reviews.
+	// "OPTION$" sorts right after every "OPTION#..." key, so only METADATA# and the options are read.
 	res, err := db.db.Query(&dynamodb.QueryInput{
 		TableName:              aws.String(db.tableName),
-		KeyConditionExpression: aws.String("#PK = :pk"),
+		KeyConditionExpression: aws.String("#PK = :pk And #SK BETWEEN :metadata AND :options"),
 		ExpressionAttributeNames: map[string]*string{
 			"#PK": aws.String("PK"),
+			"#SK": aws.String("SK"),
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":pk": {
 				S: aws.String(fmt.Sprintf("PRODUCT#%s", id)),
 			},
+			":metadata": {
+				S: aws.String("METADATA#"),
+			},
+			":options": {
+				S: aws.String("OPTION$"),
+			},
 		},
 		ScanIndexForward: aws.Bool(true),
 	})
@@ -134,14 +333,175 @@ func (db *DynamoDB) GetProduct(id SortableID) (Product, error) {
 	return result, err
 }
 
+// DeleteProduct deletes the product together with everything stored under it, like its options, and its search tokens.
+// When a BlobStore is set the product images are deleted from it as well.
+func (db *DynamoDB) DeleteProduct(id SortableID) error {
+	items, err := db.queryPartition(fmt.Sprintf("PRODUCT#%s", id))
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return ErrNotFound
+	}
+
+	var metadata Product
+	var requests []*dynamodb.WriteRequest
+	for _, item := range items {
+		if stringAttribute(item, "SK") == "METADATA#" {
+			err = dynamodbattribute.UnmarshalMap(item, &metadata)
+			if err != nil {
+				return err
+			}
+		}
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"PK": item["PK"],
+					"SK": item["SK"],
+				},
+			},
+		})
+	}
+
+	for _, err := range db.batchWrite(requests, 1) {
+		if err != nil {
+			return err
+		}
+	}
+	if err := db.unindexProduct(metadata); err != nil {
+		return err
+	}
+
+	if db.blobStore == nil {
+		return nil
+	}
+	for _, location := range []string{metadata.Image, metadata.Thumbnail} {
+		if location == "" {
+			continue
+		}
+		if err := db.blobStore.Delete(location); err != nil {
+			return fmt.Errorf("Deleted product %s, but not its image %s: %w", id, location, err)
+		}
+	}
+
+	return nil
+}
+
+// batchGetProducts fetches the METADATA# item of every product in ids, without their options.
+// The products come back in the order of ids, leaving out the ones that don't exist and duplicates.
+func (db *DynamoDB) batchGetProducts(ids []SortableID) ([]Product, error) {
+	var keys []map[string]*dynamodb.AttributeValue
+	seen := map[SortableID]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			continue // BatchGetItem refuses duplicated keys.
+		}
+		seen[id] = true
+
+		keys = append(keys, productKey(id))
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	items, err := db.batchGet(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var fetched []Product
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &fetched)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[SortableID]Product, len(fetched))
+	for _, p := range fetched {
+		byID[p.ID] = p
+	}
+
+	var products []Product
+	for _, id := range ids {
+		p, ok := byID[id]
+		if !ok {
+			continue
+		}
+		delete(byID, id) // Keeps duplicates out.
+		products = append(products, p)
+	}
+
+	return products, nil
+}
+
+// batchGetOptions fetches the option of every basket item in items, by the id of the option.
+// Options that don't exist are left out.
+func (db *DynamoDB) batchGetOptions(items []BasketItem) (map[SortableID]Option, error) {
+	var keys []map[string]*dynamodb.AttributeValue
+	seen := map[SortableID]bool{}
+	for _, item := range items {
+		if seen[item.ProductOptionID] {
+			continue // BatchGetItem refuses duplicated keys.
+		}
+		seen[item.ProductOptionID] = true
+		keys = append(keys, optionKey(item.ProductID, item.ProductOptionID))
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	fetched, err := db.batchGet(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var options []Option
+	err = dynamodbattribute.UnmarshalListOfMaps(fetched, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[SortableID]Option, len(options))
+	for _, o := range options {
+		byID[o.ID] = o
+	}
+
+	return byID, nil
+}
+
+// getProductMetadata fetches the METADATA# item of a product, without its options.
+func (db *DynamoDB) getProductMetadata(id SortableID) (Product, error) {
+	res, err := db.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(db.tableName),
+		Key:       productKey(id),
+	})
+	if err != nil {
+		return Product{}, err
+	}
+	if res.Item == nil {
+		return Product{}, ErrNotFound
+	}
+
+	var p Product
+	err = dynamodbattribute.UnmarshalMap(res.Item, &p)
+	if err != nil {
+		return Product{}, err
+	}
+
+	return p, nil
+}
+
 // GetProductsByCategory fetches all products with a specific Category and price range.
-func (db *DynamoDB) GetProductsByCategory(input *GetProductsByCategoryInput) ([]Product, ProductCategoryPaginationKey, error) {
+func (db *DynamoDB) GetProductsByCategory(input *GetProductsByCategoryInput) ([]Product, PaginationKey, error) {
 	if err := input.validate(); err != nil {
 		return nil, "", err
 	}
+	startKey, err := input.PreviousKey.decode()
+	if err != nil {
+		return nil, "", err
+	}
 
 	var result []Product
-	var lastKey ProductCategoryPaginationKey
+	var lastKey PaginationKey
 
 	res, err := db.db.Query(&dynamodb.QueryInput{
 		TableName:              aws.String(db.tableName),
@@ -153,17 +513,17 @@ func (db *DynamoDB) GetProductsByCategory(input *GetProductsByCategoryInput) ([]
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":gsi1pk": {
-				S: aws.String(fmt.Sprintf("PRODUCT#CATEGORY#%s", input.Category)),
+				S: aws.String(categoryPK(input.Category)),
 			},
 			":from": {
-				S: aws.String(zerosPricePadding(input.FromPrice)),
+				S: aws.String(zerosPadding(input.FromPrice)),
 			},
 			":to": {
-				S: aws.String(zerosPricePadding(input.ToPrice)),
+				S: aws.String(zerosPadding(input.ToPrice)),
 			},
 		},
 		Limit:             aws.Int64(int64(input.PaginationLimit)),
-		ExclusiveStartKey: decodePaginationKey(input.PreviousKey),
+		ExclusiveStartKey: startKey,
 	})
 	if err != nil {
 		return nil, "", err
@@ -191,7 +551,7 @@ type GetProductsByCategoryInput struct {
 	FromPrice       int
 	ToPrice         int
 	PaginationLimit int
-	PreviousKey     ProductCategoryPaginationKey
+	PreviousKey     PaginationKey
 }
 
 func (in *GetProductsByCategoryInput) validate() error {
@@ -214,51 +574,3 @@ func (in *GetProductsByCategoryInput) validate() error {
 
 	return nil
 }
-
-type ProductCategoryPaginationKey string
-
-func (k *ProductCategoryPaginationKey) UnmarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
-	if av.M == nil {
-		return nil
-	}
-	key := fmt.Sprintf("%s_%s_%s_%s", *av.M["PK"].S, *av.M["SK"].S, *av.M["GSI1PK"].S, *av.M["GSI1SK"].S)
-	key = base64.StdEncoding.EncodeToString([]byte(key))
-
-	*k = ProductCategoryPaginationKey(key)
-
-	return nil
-}
-
-func decodePaginationKey(pkey ProductCategoryPaginationKey) map[string]*dynamodb.AttributeValue {
-	if pkey == "" {
-		return nil
-	}
-	key, err := base64.StdEncoding.DecodeString(string(pkey))
-	if err != nil {
-		// TODO return error here instead?
-		return nil
-	}
-	s := strings.Split(string(key), "_")
-	if len(s) != 4 {
-		// TODO error
-		return nil
-	}
-
-	pk, sk, gsi, gsisk := s[0], s[1], s[2], s[3]
-
-	return map[string]*dynamodb.AttributeValue{
-		"PK": {
-			S: aws.String(pk),
-		},
-		"SK": {
-			S: aws.String(sk),
-		},
-		"GSI1PK": {
-			S: aws.String(gsi),
-		},
-		"GSI1SK": {
-			S: aws.String(gsisk),
-		},
-	}
-
-}
@@ -1,9 +1,14 @@
 package dynamodb
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/matryer/is"
 )
 
@@ -224,3 +229,176 @@ func TestGetProductsByCategoryPagination(t *testing.T) {
 	is.True(len(fetched) == 4)
 	is.True(last == "")
 }
+
+func TestGetProductsByCategoryPaginationKey(t *testing.T) {
+	is := is.New(t)
+	categoryToFetch := "Golf_Clubs" // Underscores used to break the key apart.
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := tdb.AddProduct(Product{Name: fmt.Sprintf("Test%d", i), Category: categoryToFetch})
+		is.NoErr(err)
+	}
+
+	fetched, last, err := tdb.GetProductsByCategory(&GetProductsByCategoryInput{
+		Category:        categoryToFetch,
+		PaginationLimit: 2,
+	})
+	is.NoErr(err)
+	is.Equal(len(fetched), 2)
+
+	fetched, _, err = tdb.GetProductsByCategory(&GetProductsByCategoryInput{
+		Category:    categoryToFetch,
+		PreviousKey: last,
+	})
+	is.NoErr(err)
+	is.Equal(len(fetched), 1)
+
+	_, _, err = tdb.GetProductsByCategory(&GetProductsByCategoryInput{
+		Category:    categoryToFetch,
+		PreviousKey: "not a key",
+	})
+	is.True(errors.Is(err, ErrInvalidPaginationKey))
+}
+
+func TestDeleteProduct(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	// Prepare data to get deleted
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+	_, err = tdb.AddOptionToProduct(p.ID, Option{Color: "Red", Stock: 1})
+	is.NoErr(err)
+
+	err = tdb.DeleteProduct(p.ID)
+	is.NoErr(err)
+
+	items, err := tdb.queryPartition(fmt.Sprintf("PRODUCT#%s", p.ID))
+	is.NoErr(err)
+	is.True(len(items) == 0) // The options should be gone as well.
+
+	err = tdb.DeleteProduct(p.ID)
+	is.Equal(err, ErrNotFound)
+}
+
+func TestUnmarshalProductRatings(t *testing.T) {
+	is := is.New(t)
+	item := map[string]*dynamodb.AttributeValue{
+		"Name":         {S: aws.String("Golf Club")},
+		"RatingCount":  {N: aws.String("4")},
+		"RatingSum":    {N: aws.String("14")},
+		"Rating3Count": {N: aws.String("2")},
+		"Rating4Count": {N: aws.String("0")},
+		"Rating5Count": {N: aws.String("2")},
+	}
+
+	var p Product
+	err := dynamodbattribute.UnmarshalMap(item, &p)
+	is.NoErr(err)
+	is.Equal(p.Name, "Golf Club")
+	is.Equal(p.RatingHistogram, RatingHistogram{0, 0, 2, 0, 2})
+	is.Equal(p.AverageRating, 3.5)
+}
+
+func TestProductEffectivePrice(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Product{Price: 300}.EffectivePrice(), 300)
+	is.Equal(Product{Price: 300, Sale: 200}.EffectivePrice(), 200)
+	is.Equal(Product{Price: 300, Sale: 400}.EffectivePrice(), 300) // Not a discount.
+}
+
+func TestAddProductResetsMaintainedFields(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	updated := time.Now()
+	p, err := tdb.AddProduct(Product{
+		Name:        "Fake Reviews",
+		Category:    "Clubs",
+		RatingCount: 1000,
+		RatingSum:   5000,
+		Version:     99,
+		UpdatedBy:   "someone@tewq.com",
+		UpdatedDate: &updated,
+	})
+	is.NoErr(err)
+
+	fetched, err := tdb.GetProduct(p.ID)
+	is.NoErr(err)
+	is.Equal(fetched.RatingCount, 0)
+	is.Equal(fetched.AverageRating, 0.0)
+	is.Equal(fetched.Version, 1)
+	is.Equal(fetched.UpdatedBy, "")
+	is.True(fetched.UpdatedDate == nil)
+}
+
+func TestAddProductWithID(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	id := NewSortableID()
+	created := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	p, err := tdb.AddProduct(Product{ID: id, CreatedDate: created, Name: "Iron", Category: "Clubs"})
+	is.NoErr(err)
+	is.Equal(p.ID, id)
+	is.True(p.CreatedDate.Equal(created))
+
+	_, err = tdb.AddProduct(Product{ID: id, Name: "Another Iron", Category: "Clubs"})
+	is.Equal(err, ErrConflict) // The ID is taken.
+
+	optionID := NewSortableID()
+	o, err := tdb.AddOptionToProduct(id, Option{ID: optionID, Color: "Black"})
+	is.NoErr(err)
+	is.Equal(o.ID, optionID)
+
+	_, err = tdb.AddOptionToProduct(id, Option{ID: optionID, Color: "Blue"})
+	is.Equal(err, ErrConflict)
+
+	fetched, err := tdb.GetProduct(id)
+	is.NoErr(err)
+	is.Equal(fetched.Name, "Iron")
+	is.Equal(len(fetched.Options), 1)
+	is.Equal(fetched.Options[0].Color, "Black")
+}
+
+func TestClockAndIDGenerator(t *testing.T) {
+	is := is.New(t)
+
+	now := time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC)
+	ids := []SortableID{NewSortableID(), NewSortableID()}
+	next := 0
+	tdb, err := NewTestDynamoDB(
+		WithClock(func() time.Time { return now }),
+		WithIDGenerator(func() SortableID {
+			id := ids[next]
+			next++
+			return id
+		}),
+	)
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Hybrid", Category: "Clubs"})
+	is.NoErr(err)
+	is.Equal(p.ID, ids[0])
+	is.True(p.CreatedDate.Equal(now))
+
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Grey"})
+	is.NoErr(err)
+	is.Equal(o.ID, ids[1])
+	is.True(o.CreatedDate.Equal(now))
+}
@@ -0,0 +1,537 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ReviewStatus is where a review is in the moderation workflow.
+type ReviewStatus string
+
+const (
+	// ReviewPending reviews wait in the moderation queue, and aren't shown or counted in the product ratings.
+	ReviewPending ReviewStatus = "pending"
+	// ReviewApproved reviews are live. Reviews written before moderation existed have no status, and are approved as well.
+	ReviewApproved ReviewStatus = "approved"
+	// ReviewRejected reviews are kept, but never shown.
+	ReviewRejected ReviewStatus = "rejected"
+)
+
+// pendingReviewsPK is the GSI2 partition of the moderation queue, reviews leave it once they're moderated.
+const pendingReviewsPK = "REVIEW#PENDING"
+
+// Review is what a customer thinks about a product.
+type Review struct {
+	ID          SortableID `json:"id" dynamodbav:"Id,omitempty"`
+	ProductID   SortableID `json:"productId" dynamodbav:"ProductId,omitempty"`
+	CustomerID  SortableID `json:"customerId" dynamodbav:"CustomerId,omitempty"`
+	CreatedDate time.Time  `json:"createdUtc" dynamodbav:"CreatedUtc,omitempty"`
+	Rating      int        `json:"rating" dynamodbav:"Rating,omitempty"` // 1 to 5 stars.
+	Title       string     `json:"title" dynamodbav:"Title,omitempty"`
+	Body        string     `json:"body" dynamodbav:"Body,omitempty"`
+
+	// VerifiedPurchase is set by AddReview when the customer has an order of the product that isn't cancelled or refunded.
+	VerifiedPurchase bool `json:"verifiedPurchase" dynamodbav:"VerifiedPurchase,omitempty"`
+
+	Status        ReviewStatus `json:"status" dynamodbav:"Status,omitempty"`
+	ModeratedDate *time.Time   `json:"moderatedUtc,omitempty" dynamodbav:"ModeratedUtc,omitempty"`
+}
+
+// counted tells if the rating of the review is part of the product ratings.
+func (r Review) counted() bool {
+	return r.Status == ReviewApproved || r.Status == ""
+}
+
+func (r Review) validate() error {
+	if r.CustomerID == (SortableID{}) {
+		return errors.New("Expected CustomerID to have a value.")
+	}
+	if r.Rating < 1 || r.Rating > len(RatingHistogram{}) {
+		return fmt.Errorf("Expected Rating to be between 1 and %d, got %d.", len(RatingHistogram{}), r.Rating)
+	}
+
+	return nil
+}
+
+// reviewerSK is the sort key of the item marking that a customer has reviewed the product of the partition.
+func reviewerSK(customerID SortableID) string {
+	return fmt.Sprintf("REVIEWER#%s", customerID)
+}
+
+// AddReview adds a review to a product, waiting in the moderation queue until it's approved or rejected.
+// A customer can only review a product once, a second review fails with ErrAlreadyReviewed.
+func (db *DynamoDB) AddReview(review Review) (Review, error) {
+	if err := review.validate(); err != nil {
+		return Review{}, err
+	}
+
+	verified, err := db.hasOrdered(review.CustomerID, review.ProductID)
+	if err != nil {
+		return Review{}, err
+	}
+
+	review.ID = db.newID()
+	review.CreatedDate = db.now()
+	review.VerifiedPurchase = verified
+	review.Status = ReviewPending
+	review.ModeratedDate = nil
+
+	item, err := dynamodbattribute.MarshalMap(&review)
+	if err != nil {
+		return Review{}, err
+	}
+	item["Type"] = &dynamodb.AttributeValue{S: aws.String("review")}
+	item["PK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("PRODUCT#%s", review.ProductID))}
+	item["SK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("REVIEW#%s", review.ID))}
+	item["GSI1PK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("USER#%s", review.CustomerID))}
+	item["GSI1SK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("REVIEW#%s", review.ID))}
+	item["GSI2PK"] = &dynamodb.AttributeValue{S: aws.String(pendingReviewsPK)}
+	item["GSI2SK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("REVIEW#%s", review.ID))}
+
+	_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(db.tableName),
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(#PK)"),
+					ExpressionAttributeNames: map[string]*string{
+						"#PK": aws.String("PK"),
+					},
+				},
+			},
+			{
+				ConditionCheck: &dynamodb.ConditionCheck{
+					TableName: aws.String(db.tableName),
+					Key: map[string]*dynamodb.AttributeValue{
+						"PK": item["PK"],
+						"SK": {S: aws.String("METADATA#")},
+					},
+					ConditionExpression: aws.String("attribute_exists(#PK)"),
+					ExpressionAttributeNames: map[string]*string{
+						"#PK": aws.String("PK"),
+					},
+				},
+			},
+			{
+				// The sentinel makes the transaction fail when the customer already has a review of the product.
+				Put: &dynamodb.Put{
+					TableName: aws.String(db.tableName),
+					Item: map[string]*dynamodb.AttributeValue{
+						"PK":       item["PK"],
+						"SK":       {S: aws.String(reviewerSK(review.CustomerID))},
+						"Type":     {S: aws.String("reviewer")},
+						"ReviewId": {S: aws.String(review.ID.String())},
+					},
+					ConditionExpression: aws.String("attribute_not_exists(#PK)"),
+					ExpressionAttributeNames: map[string]*string{
+						"#PK": aws.String("PK"),
+					},
+				},
+			},
+		},
+	})
+	if conditionFailedAt(err, 1) {
+		return Review{}, ErrNotFound
+	}
+	if conditionFailedAt(err, 2) {
+		return Review{}, ErrAlreadyReviewed
+	}
+	if err != nil {
+		return Review{}, err
+	}
+
+	return review, nil
+}
+
+// DeleteReview deletes a review, taking its rating out of the product aggregates in the same transaction when it was approved.
+// The customer is free to review the product again afterwards.
+func (db *DynamoDB) DeleteReview(productID, reviewID SortableID) error {
+	review, err := db.GetReview(productID, reviewID)
+	if err != nil {
+		return err
+	}
+
+	// The review must still have the status it was read with, or the ratings could end up off by one.
+	condition := "attribute_exists(#PK) AND #Status = :status"
+	names := map[string]*string{
+		"#PK":     aws.String("PK"),
+		"#Status": aws.String("Status"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":status": {S: aws.String(string(review.Status))},
+	}
+	if review.Status == "" {
+		condition = "attribute_exists(#PK) AND attribute_not_exists(#Status)"
+		values = nil
+	}
+
+	items := []*dynamodb.TransactWriteItem{
+		{
+			Delete: &dynamodb.Delete{
+				TableName: aws.String(db.tableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"PK": {
+						S: aws.String(fmt.Sprintf("PRODUCT#%s", productID)),
+					},
+					"SK": {
+						S: aws.String(fmt.Sprintf("REVIEW#%s", reviewID)),
+					},
+				},
+				ConditionExpression:       aws.String(condition),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		},
+		{
+			// Lets the customer review the product again.
+			Delete: &dynamodb.Delete{
+				TableName: aws.String(db.tableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"PK": {
+						S: aws.String(fmt.Sprintf("PRODUCT#%s", productID)),
+					},
+					"SK": {
+						S: aws.String(reviewerSK(review.CustomerID)),
+					},
+				},
+			},
+		},
+	}
+	if review.counted() {
+		items = append(items, db.rateProduct(productID, review.Rating, -1))
+	}
+
+	_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if conditionFailedAt(err, 0) {
+		return ErrConflict
+	}
+
+	return err
+}
+
+// ApproveReview makes a pending review live, adding its rating to the product aggregates in the same transaction.
+func (db *DynamoDB) ApproveReview(productID, reviewID SortableID) (Review, error) {
+	review, err := db.GetReview(productID, reviewID)
+	if err != nil {
+		return Review{}, err
+	}
+
+	return db.moderateReview(review, ReviewApproved)
+}
+
+// RejectReview takes a pending review out of the moderation queue without ever showing it.
+func (db *DynamoDB) RejectReview(productID, reviewID SortableID) (Review, error) {
+	review, err := db.GetReview(productID, reviewID)
+	if err != nil {
+		return Review{}, err
+	}
+
+	return db.moderateReview(review, ReviewRejected)
+}
+
+func (db *DynamoDB) moderateReview(review Review, status ReviewStatus) (Review, error) {
+	if review.Status != ReviewPending {
+		return Review{}, ErrAlreadyModerated
+	}
+
+	now := db.now()
+	review.Status = status
+	review.ModeratedDate = &now
+	moderatedDate, err := dynamodbattribute.Marshal(now)
+	if err != nil {
+		return Review{}, err
+	}
+
+	items := []*dynamodb.TransactWriteItem{
+		{
+			Update: &dynamodb.Update{
+				TableName: aws.String(db.tableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"PK": {
+						S: aws.String(fmt.Sprintf("PRODUCT#%s", review.ProductID)),
+					},
+					"SK": {
+						S: aws.String(fmt.Sprintf("REVIEW#%s", review.ID)),
+					},
+				},
+				// Two moderators racing each other mustn't count the rating twice.
+				ConditionExpression: aws.String("#Status = :pending"),
+				UpdateExpression:    aws.String("SET #Status = :status, #ModeratedUtc = :moderated REMOVE #GSI2PK, #GSI2SK"),
+				ExpressionAttributeNames: map[string]*string{
+					"#Status":       aws.String("Status"),
+					"#ModeratedUtc": aws.String("ModeratedUtc"),
+					"#GSI2PK":       aws.String("GSI2PK"),
+					"#GSI2SK":       aws.String("GSI2SK"),
+				},
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":pending":   {S: aws.String(string(ReviewPending))},
+					":status":    {S: aws.String(string(status))},
+					":moderated": moderatedDate,
+				},
+			},
+		},
+	}
+	if review.counted() {
+		items = append(items, db.rateProduct(review.ProductID, review.Rating, 1))
+	}
+
+	_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if conditionFailedAt(err, 0) {
+		return Review{}, ErrAlreadyModerated
+	}
+	if conditionFailedAt(err, 1) {
+		return Review{}, ErrNotFound
+	}
+	if err != nil {
+		return Review{}, err
+	}
+
+	return review, nil
+}
+
+// ListPendingReviewsInput pages through the moderation queue.
+type ListPendingReviewsInput struct {
+	PaginationLimit int
+	PreviousKey     PaginationKey
+}
+
+func (in *ListPendingReviewsInput) validate() error {
+	if in.PaginationLimit == 0 {
+		in.PaginationLimit = 20
+	}
+
+	return nil
+}
+
+// ListPendingReviews lists the reviews waiting for moderation, oldest first.
+func (db *DynamoDB) ListPendingReviews(input *ListPendingReviewsInput) ([]Review, PaginationKey, error) {
+	if err := input.validate(); err != nil {
+		return nil, "", err
+	}
+
+	startKey, err := input.PreviousKey.decode()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var result []Review
+	var lastKey PaginationKey
+
+	res, err := db.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(db.tableName),
+		IndexName:              aws.String("GSI2"),
+		KeyConditionExpression: aws.String("#GSI2PK = :gsi2pk"),
+		ExpressionAttributeNames: map[string]*string{
+			"#GSI2PK": aws.String("GSI2PK"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":gsi2pk": {
+				S: aws.String(pendingReviewsPK),
+			},
+		},
+		Limit:             aws.Int64(int64(input.PaginationLimit)),
+		ExclusiveStartKey: startKey,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(res.LastEvaluatedKey, &lastKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(res.Items, &result)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return result, lastKey, nil
+}
+
+// hasOrdered tells if the customer has ordered the product, by the purchase marker placing the order wrote.
+// The marker counts the orders of the product that haven't been cancelled or refunded, see purchaseItem.
+func (db *DynamoDB) hasOrdered(customerID, productID SortableID) (bool, error) {
+	res, err := db.db.GetItem(&dynamodb.GetItemInput{
+		TableName:            aws.String(db.tableName),
+		Key:                  purchaseKey(customerID, productID),
+		ProjectionExpression: aws.String("#Orders"),
+		ExpressionAttributeNames: map[string]*string{
+			"#Orders": aws.String("Orders"),
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var marker struct {
+		Orders int `dynamodbav:"Orders"`
+	}
+	if err := dynamodbattribute.UnmarshalMap(res.Item, &marker); err != nil {
+		return false, err
+	}
+
+	return marker.Orders > 0, nil
+}
+
+// rateProduct is the update adding, or with a negative delta removing, a rating to the aggregates of a product.
+func (db *DynamoDB) rateProduct(productID SortableID, rating, delta int) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName: aws.String(db.tableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"PK": {
+					S: aws.String(fmt.Sprintf("PRODUCT#%s", productID)),
+				},
+				"SK": {
+					S: aws.String("METADATA#"),
+				},
+			},
+			ConditionExpression: aws.String("attribute_exists(#PK)"),
+			UpdateExpression:    aws.String("ADD #RatingCount :count, #RatingSum :sum, #Bucket :count"),
+			ExpressionAttributeNames: map[string]*string{
+				"#PK":          aws.String("PK"),
+				"#RatingCount": aws.String("RatingCount"),
+				"#RatingSum":   aws.String("RatingSum"),
+				"#Bucket":      aws.String(ratingBucket(rating)),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":count": {
+					N: aws.String(fmt.Sprintf("%d", delta)),
+				},
+				":sum": {
+					N: aws.String(fmt.Sprintf("%d", delta*rating)),
+				},
+			},
+		},
+	}
+}
+
+// GetReview fetches a single review of a product.
+func (db *DynamoDB) GetReview(productID, reviewID SortableID) (Review, error) {
+	res, err := db.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(db.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {
+				S: aws.String(fmt.Sprintf("PRODUCT#%s", productID)),
+			},
+			"SK": {
+				S: aws.String(fmt.Sprintf("REVIEW#%s", reviewID)),
+			},
+		},
+	})
+	if err != nil {
+		return Review{}, err
+	}
+	if res.Item == nil {
+		return Review{}, ErrNotFound
+	}
+
+	var review Review
+	err = dynamodbattribute.UnmarshalMap(res.Item, &review)
+	if err != nil {
+		return Review{}, err
+	}
+
+	return review, nil
+}
+
+// GetProductReviewsInput tells which reviews of which product to fetch.
+type GetProductReviewsInput struct {
+	ProductID SortableID // required
+	// Status defaults to ReviewApproved, so only live reviews are shown.
+	// Since the status is filtered on, a page can hold fewer reviews than the PaginationLimit.
+	Status          ReviewStatus
+	PaginationLimit int
+	PreviousKey     PaginationKey
+}
+
+func (in *GetProductReviewsInput) validate() error {
+	if in.ProductID == (SortableID{}) {
+		return errors.New("Expected ProductID to have a value.")
+	}
+
+	switch in.Status {
+	case "":
+		in.Status = ReviewApproved
+	case ReviewPending, ReviewApproved, ReviewRejected:
+	default:
+		return fmt.Errorf("Unknown review status %q.", in.Status)
+	}
+
+	if in.PaginationLimit == 0 {
+		in.PaginationLimit = 20
+	}
+
+	return nil
+}
+
+// GetProductReviews fetches the reviews of a product, newest first.
+func (db *DynamoDB) GetProductReviews(input *GetProductReviewsInput) ([]Review, PaginationKey, error) {
+	if err := input.validate(); err != nil {
+		return nil, "", err
+	}
+
+	filter := "#Status = :status"
+	if input.Status == ReviewApproved {
+		filter += " OR attribute_not_exists(#Status)"
+	}
+
+	startKey, err := input.PreviousKey.decode()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var result []Review
+	var lastKey PaginationKey
+
+	res, err := db.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(db.tableName),
+		KeyConditionExpression: aws.String("#PK = :pk And begins_with(#SK, :reviews)"),
+		FilterExpression:       aws.String(filter),
+		ExpressionAttributeNames: map[string]*string{
+			"#PK":     aws.String("PK"),
+			"#SK":     aws.String("SK"),
+			"#Status": aws.String("Status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": {
+				S: aws.String(fmt.Sprintf("PRODUCT#%s", input.ProductID)),
+			},
+			":reviews": {
+				S: aws.String("REVIEW#"),
+			},
+			":status": {
+				S: aws.String(string(input.Status)),
+			},
+		},
+		ScanIndexForward:  aws.Bool(false),
+		Limit:             aws.Int64(int64(input.PaginationLimit)),
+		ExclusiveStartKey: startKey,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(res.LastEvaluatedKey, &lastKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(res.Items, &result)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return result, lastKey, nil
+}
@@ -0,0 +1,252 @@
+package dynamodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestAddReview(t *testing.T) {
+	is := is.New(t)
+
+	// Review ids only tell the second, so the clock moves on between reviews to keep them in order.
+	now := time.Now()
+	tdb, err := NewTestDynamoDB(WithClock(func() time.Time { return now }))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	// Prepare data to get reviewed
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+	_, err = tdb.AddOptionToProduct(p.ID, Option{Color: "Red", Stock: 1})
+	is.NoErr(err)
+
+	for _, rating := range []int{4, 5, 5} {
+		now = now.Add(time.Second)
+		r, err := tdb.AddReview(Review{
+			ProductID:  p.ID,
+			CustomerID: NewSortableID(),
+			Rating:     rating,
+			Title:      "Great club",
+		})
+		is.NoErr(err)
+		is.Equal(r.Status, ReviewPending)
+
+		_, err = tdb.ApproveReview(p.ID, r.ID)
+		is.NoErr(err)
+	}
+
+	fetched, err := tdb.GetProduct(p.ID)
+	is.NoErr(err)
+	is.Equal(fetched.RatingCount, 3)
+	is.Equal(fetched.RatingHistogram, RatingHistogram{0, 0, 0, 1, 2})
+	is.True(fetched.AverageRating > 4.66 && fetched.AverageRating < 4.67)
+	is.True(len(fetched.Options) == 1) // Reviews live in the same partition, but aren't options.
+
+	reviews, _, err := tdb.GetProductReviews(&GetProductReviewsInput{ProductID: p.ID})
+	is.NoErr(err)
+	is.True(len(reviews) == 3)
+	is.Equal(reviews[0].Rating, 5) // Newest first.
+	is.Equal(reviews[2].Rating, 4)
+}
+
+func TestAddReviewValidation(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+
+	_, err = tdb.AddReview(Review{ProductID: p.ID, CustomerID: NewSortableID(), Rating: 6})
+	is.True(err != nil) // There's only 5 stars.
+
+	_, err = tdb.AddReview(Review{ProductID: NewSortableID(), CustomerID: NewSortableID(), Rating: 5})
+	is.Equal(err, ErrNotFound) // The product doesn't exist.
+}
+
+func TestDeleteReview(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	// Prepare data to get deleted
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+	kept, err := tdb.AddReview(Review{ProductID: p.ID, CustomerID: NewSortableID(), Rating: 2})
+	is.NoErr(err)
+	_, err = tdb.ApproveReview(p.ID, kept.ID)
+	is.NoErr(err)
+	deleted, err := tdb.AddReview(Review{ProductID: p.ID, CustomerID: NewSortableID(), Rating: 4})
+	is.NoErr(err)
+	_, err = tdb.ApproveReview(p.ID, deleted.ID)
+	is.NoErr(err)
+
+	err = tdb.DeleteReview(p.ID, deleted.ID)
+	is.NoErr(err)
+
+	fetched, err := tdb.GetProduct(p.ID)
+	is.NoErr(err)
+	is.Equal(fetched.RatingCount, 1)
+	is.Equal(fetched.AverageRating, float64(kept.Rating))
+	is.Equal(fetched.RatingHistogram, RatingHistogram{0, 1, 0, 0, 0})
+
+	err = tdb.DeleteReview(p.ID, deleted.ID)
+	is.Equal(err, ErrNotFound)
+}
+
+func TestAddReviewVerifiedPurchase(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+	other, err := tdb.AddProduct(Product{Name: "Golf Shoe", Category: "Shoes"})
+	is.NoErr(err)
+
+	_, err = tdb.AddOrder(Order{
+		CustomerID: customerID,
+		Items: []OrderLineItem{
+			{ProductID: p.ID, UnitPrice: 100, Quantity: 1},
+			{ProductID: p.ID, UnitPrice: 100, Quantity: 2}, // Another option of the same product.
+		},
+	})
+	is.NoErr(err)
+
+	verified, err := tdb.AddReview(Review{ProductID: p.ID, CustomerID: customerID, Rating: 5})
+	is.NoErr(err)
+	is.True(verified.VerifiedPurchase)
+
+	unverified, err := tdb.AddReview(Review{ProductID: other.ID, CustomerID: customerID, Rating: 4})
+	is.NoErr(err)
+	is.True(!unverified.VerifiedPurchase) // Only the club was ordered.
+}
+
+func TestAddReviewCancelledPurchase(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+
+	first, err := tdb.AddOrder(Order{CustomerID: customerID, Items: []OrderLineItem{{ProductID: p.ID, UnitPrice: 100, Quantity: 1}}})
+	is.NoErr(err)
+	second, err := tdb.AddOrder(Order{CustomerID: customerID, Items: []OrderLineItem{{ProductID: p.ID, UnitPrice: 100, Quantity: 1}}})
+	is.NoErr(err)
+
+	_, err = tdb.TransitionOrder(first.ID, OrderCancelled)
+	is.NoErr(err)
+	ordered, err := tdb.hasOrdered(customerID, p.ID)
+	is.NoErr(err)
+	is.True(ordered) // The second order still stands.
+
+	_, err = tdb.TransitionOrder(second.ID, OrderPaid)
+	is.NoErr(err)
+	_, err = tdb.TransitionOrder(second.ID, OrderRefunded)
+	is.NoErr(err)
+
+	review, err := tdb.AddReview(Review{ProductID: p.ID, CustomerID: customerID, Rating: 1})
+	is.NoErr(err)
+	is.True(!review.VerifiedPurchase)
+}
+
+func TestAddReviewOncePerCustomer(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+
+	first, err := tdb.AddReview(Review{ProductID: p.ID, CustomerID: customerID, Rating: 3})
+	is.NoErr(err)
+	is.True(!first.VerifiedPurchase) // The customer never ordered the club.
+
+	_, err = tdb.ApproveReview(p.ID, first.ID)
+	is.NoErr(err)
+
+	_, err = tdb.AddReview(Review{ProductID: p.ID, CustomerID: customerID, Rating: 5})
+	is.Equal(err, ErrAlreadyReviewed)
+
+	fetched, err := tdb.GetProduct(p.ID)
+	is.NoErr(err)
+	is.Equal(fetched.RatingCount, 1) // The second review shouldn't count.
+
+	err = tdb.DeleteReview(p.ID, first.ID)
+	is.NoErr(err)
+
+	_, err = tdb.AddReview(Review{ProductID: p.ID, CustomerID: customerID, Rating: 5})
+	is.NoErr(err) // With the first review gone, the customer can review it again.
+}
+
+func TestReviewModeration(t *testing.T) {
+	is := is.New(t)
+
+	now := time.Now()
+	tdb, err := NewTestDynamoDB(WithClock(func() time.Time { return now }))
+	is.NoErr(err)
+	defer tdb.Close()
+
+	// Prepare data to get moderated
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+	approved, err := tdb.AddReview(Review{ProductID: p.ID, CustomerID: NewSortableID(), Rating: 5})
+	is.NoErr(err)
+	now = now.Add(time.Second) // Review ids only tell the second.
+	rejected, err := tdb.AddReview(Review{ProductID: p.ID, CustomerID: NewSortableID(), Rating: 1})
+	is.NoErr(err)
+
+	pending, _, err := tdb.ListPendingReviews(&ListPendingReviewsInput{})
+	is.NoErr(err)
+	is.True(len(pending) == 2)
+	is.Equal(pending[0].ID, approved.ID) // Oldest first.
+
+	reviews, _, err := tdb.GetProductReviews(&GetProductReviewsInput{ProductID: p.ID})
+	is.NoErr(err)
+	is.True(len(reviews) == 0) // Nothing is approved yet.
+
+	_, err = tdb.ApproveReview(p.ID, approved.ID)
+	is.NoErr(err)
+	r, err := tdb.RejectReview(p.ID, rejected.ID)
+	is.NoErr(err)
+	is.Equal(r.Status, ReviewRejected)
+	is.True(r.ModeratedDate != nil)
+
+	_, err = tdb.ApproveReview(p.ID, rejected.ID)
+	is.Equal(err, ErrAlreadyModerated)
+
+	pending, _, err = tdb.ListPendingReviews(&ListPendingReviewsInput{})
+	is.NoErr(err)
+	is.True(len(pending) == 0) // Moderated reviews leave the queue.
+
+	reviews, _, err = tdb.GetProductReviews(&GetProductReviewsInput{ProductID: p.ID})
+	is.NoErr(err)
+	is.True(len(reviews) == 1)
+	is.Equal(reviews[0].ID, approved.ID)
+
+	reviews, _, err = tdb.GetProductReviews(&GetProductReviewsInput{ProductID: p.ID, Status: ReviewRejected})
+	is.NoErr(err)
+	is.True(len(reviews) == 1)
+	is.Equal(reviews[0].ID, rejected.ID)
+
+	fetched, err := tdb.GetProduct(p.ID)
+	is.NoErr(err)
+	is.Equal(fetched.RatingCount, 1) // The rejected review doesn't count.
+	is.Equal(fetched.AverageRating, float64(5))
+}
@@ -0,0 +1,320 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// stopWords are too common to be worth indexing.
+var stopWords = map[string]bool{
+	"an": true, "and": true, "the": true, "of": true, "for": true,
+	"with": true, "in": true, "on": true, "to": true, "or": true,
+}
+
+// tokenize splits text into lower cased terms, leaving out stop words, single characters and duplicates.
+func tokenize(text string) []string {
+	var terms []string
+	seen := map[string]bool{}
+
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, f := range fields {
+		if utf8.RuneCountInString(f) < 2 || stopWords[f] || seen[f] {
+			continue
+		}
+		seen[f] = true
+		terms = append(terms, f)
+	}
+
+	return terms
+}
+
+// maxProductTerms is the most terms a product can have, so its tokens fit in the transaction adding it
+// together with the product itself and an idempotency record.
+const maxProductTerms = transactWriteLimit - 2
+
+// productTerms are the terms a product can be found by, the terms of the name first.
+func productTerms(p Product) []string {
+	return tokenize(p.Name + " " + p.Description)
+}
+
+// validateProductTerms fails with ErrTooManySearchTerms when p has more terms than maxProductTerms,
+// instead of leaving some of them out of the search.
+func validateProductTerms(p Product) error {
+	if n := len(productTerms(p)); n > maxProductTerms {
+		return fmt.Errorf("%w: %d terms in the name and description, at most %d are allowed", ErrTooManySearchTerms, n, maxProductTerms)
+	}
+	return nil
+}
+
+// tokenPrefixPK groups the tokens by their first character in GSI1, so they can be prefix matched with begins_with.
+func tokenPrefixPK(term string) string {
+	r, _ := utf8.DecodeRuneInString(term)
+	return fmt.Sprintf("TOKENPREFIX#%c", r)
+}
+
+// tokenItems are the inverted index items pointing from every term of p back to p.
+func tokenItems(p Product) []map[string]*dynamodb.AttributeValue {
+	var items []map[string]*dynamodb.AttributeValue
+	for _, term := range productTerms(p) {
+		items = append(items, map[string]*dynamodb.AttributeValue{
+			"PK":        {S: aws.String(fmt.Sprintf("TOKEN#%s", term))},
+			"SK":        {S: aws.String(fmt.Sprintf("PRODUCT#%s", p.ID))},
+			"GSI1PK":    {S: aws.String(tokenPrefixPK(term))},
+			"GSI1SK":    {S: aws.String(fmt.Sprintf("%s#PRODUCT#%s", term, p.ID))},
+			"Type":      {S: aws.String("search_token")},
+			"Term":      {S: aws.String(term)},
+			"ProductId": {S: aws.String(p.ID.String())},
+		})
+	}
+	return items
+}
+
+// indexWrites are the puts of the search tokens of p, to add to the transaction writing p.
+func (db *DynamoDB) indexWrites(p Product) []*dynamodb.TransactWriteItem {
+	var writes []*dynamodb.TransactWriteItem
+	for _, item := range tokenItems(p) {
+		writes = append(writes, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{TableName: aws.String(db.tableName), Item: item},
+		})
+	}
+	return writes
+}
+
+// unindexWrites are the deletes of the search tokens of p, to add to the transaction writing p.
+func (db *DynamoDB) unindexWrites(p Product) []*dynamodb.TransactWriteItem {
+	var writes []*dynamodb.TransactWriteItem
+	for _, item := range tokenItems(p) {
+		writes = append(writes, &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName: aws.String(db.tableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"PK": item["PK"],
+					"SK": item["SK"],
+				},
+			},
+		})
+	}
+	return writes
+}
+
+// unindexProduct deletes the search tokens of p.
+func (db *DynamoDB) unindexProduct(p Product) error {
+	var requests []*dynamodb.WriteRequest
+	for _, item := range tokenItems(p) {
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"PK": item["PK"],
+					"SK": item["SK"],
+				},
+			},
+		})
+	}
+
+	for _, err := range db.batchWrite(requests, 1) {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reindexWrites are the writes moving the search tokens of a product from what it was, old, to what it is now, p,
+// to add to the transaction changing it. Only the terms that changed are written, archived products have no tokens.
+func (db *DynamoDB) reindexWrites(old, p Product) []*dynamodb.TransactWriteItem {
+	terms := map[string]bool{}
+	if !old.Archived {
+		for _, term := range productTerms(old) {
+			terms[term] = true
+		}
+	}
+
+	var writes []*dynamodb.TransactWriteItem
+	kept := map[string]bool{}
+	if !p.Archived {
+		for _, item := range tokenItems(p) {
+			term := stringAttribute(item, "Term")
+			kept[term] = true
+			if terms[term] {
+				continue // The token is there already.
+			}
+			writes = append(writes, &dynamodb.TransactWriteItem{
+				Put: &dynamodb.Put{TableName: aws.String(db.tableName), Item: item},
+			})
+		}
+	}
+	if !old.Archived {
+		for _, item := range tokenItems(old) {
+			if kept[stringAttribute(item, "Term")] {
+				continue
+			}
+			writes = append(writes, &dynamodb.TransactWriteItem{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(db.tableName),
+					Key: map[string]*dynamodb.AttributeValue{
+						"PK": item["PK"],
+						"SK": item["SK"],
+					},
+				},
+			})
+		}
+	}
+
+	return writes
+}
+
+// SearchProductsInput is what to search for.
+type SearchProductsInput struct {
+	Query string // required
+	// Prefix matches the last term of the query as a prefix, so "golf dri" finds "Golf Driver" while it's being typed.
+	Prefix bool
+	Limit  int
+}
+
+func (in *SearchProductsInput) validate() error {
+	if len(tokenize(in.Query)) == 0 {
+		return errors.New("Expected Query to have at least one searchable term.")
+	}
+
+	if in.Limit == 0 {
+		in.Limit = 20
+	}
+
+	return nil
+}
+
+// SearchResult is a product matching a search, Score being how many of the query terms it matched.
+type SearchResult struct {
+	Product Product `json:"product"`
+	Score   int     `json:"score"`
+}
+
+// SearchProducts finds the products whose Name or Description contains the terms of the query.
+// The products matching the most terms come first, the products don't include their options.
+func (db *DynamoDB) SearchProducts(input *SearchProductsInput) ([]SearchResult, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+
+	terms := tokenize(input.Query)
+	scores := map[SortableID]int{}
+	for i, term := range terms {
+		var ids []SortableID
+		var err error
+		if input.Prefix && i == len(terms)-1 {
+			ids, err = db.productsByTermPrefix(term)
+		} else {
+			ids, err = db.productsByTerm(term)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			scores[id]++
+		}
+	}
+
+	ranked := make([]SortableID, 0, len(scores))
+	for id := range scores {
+		ranked = append(ranked, id)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if scores[ranked[i]] != scores[ranked[j]] {
+			return scores[ranked[i]] > scores[ranked[j]]
+		}
+		return ranked[i].String() > ranked[j].String() // Newest first.
+	})
+	if len(ranked) > input.Limit {
+		ranked = ranked[:input.Limit]
+	}
+
+	products, err := db.batchGetProducts(ranked)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(products))
+	for _, p := range products {
+		results = append(results, SearchResult{Product: p, Score: scores[p.ID]})
+	}
+
+	return results, nil
+}
+
+// productsByTerm finds the products containing exactly term.
+func (db *DynamoDB) productsByTerm(term string) ([]SortableID, error) {
+	items, err := db.queryPartition(fmt.Sprintf("TOKEN#%s", term))
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenProductIDs(items)
+}
+
+// productsByTermPrefix finds the products containing a term starting with prefix.
+func (db *DynamoDB) productsByTermPrefix(prefix string) ([]SortableID, error) {
+	var items []map[string]*dynamodb.AttributeValue
+
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		res, err := db.db.Query(&dynamodb.QueryInput{
+			TableName:              aws.String(db.tableName),
+			IndexName:              aws.String("GSI1"),
+			KeyConditionExpression: aws.String("#GSI1PK = :gsi1pk And begins_with(#GSI1SK, :prefix)"),
+			ExpressionAttributeNames: map[string]*string{
+				"#GSI1PK": aws.String("GSI1PK"),
+				"#GSI1SK": aws.String("GSI1SK"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":gsi1pk": {
+					S: aws.String(tokenPrefixPK(prefix)),
+				},
+				":prefix": {
+					S: aws.String(prefix),
+				},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, res.Items...)
+
+		if len(res.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = res.LastEvaluatedKey
+	}
+
+	return tokenProductIDs(items)
+}
+
+// tokenProductIDs returns the products the token items point to, once each.
+// A prefix can match several terms of the same product, which still only counts as one match.
+func tokenProductIDs(items []map[string]*dynamodb.AttributeValue) ([]SortableID, error) {
+	var ids []SortableID
+	seen := map[SortableID]bool{}
+	for _, item := range items {
+		var id SortableID
+		if err := id.UnmarshalDynamoDBAttributeValue(item["ProductId"]); err != nil {
+			return nil, err
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,163 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestTokenize(t *testing.T) {
+	is := is.New(t)
+
+	terms := tokenize("The Golf-Club for Golfers, a golf club with 2 grips!")
+	is.Equal(terms, []string{"golf", "club", "golfers", "grips"})
+
+	is.True(len(tokenize("a the of")) == 0) // Only stop words and single characters.
+	is.Equal(tokenize("Größe Ärmel"), []string{"größe", "ärmel"})
+}
+
+func TestProductTerms(t *testing.T) {
+	is := is.New(t)
+
+	var description strings.Builder
+	for i := 0; i < 2*maxProductTerms; i++ {
+		fmt.Fprintf(&description, "word%d ", i)
+	}
+
+	p := Product{Name: "Golf Club", Description: description.String()}
+	terms := productTerms(p)
+	is.Equal(len(terms), 2+2*maxProductTerms)
+	is.Equal(terms[:2], []string{"golf", "club"})
+	is.True(errors.Is(validateProductTerms(p), ErrTooManySearchTerms)) // The tokens wouldn't fit in the transaction adding the product.
+
+	p.Description = ""
+	is.NoErr(validateProductTerms(p))
+}
+
+func TestAddProductTooManySearchTerms(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	var description strings.Builder
+	for i := 0; i < maxProductTerms; i++ {
+		fmt.Fprintf(&description, "word%d ", i)
+	}
+
+	_, err = tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs", Description: description.String()})
+	is.True(errors.Is(err, ErrTooManySearchTerms))
+
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+	p.Description = description.String()
+	_, err = tdb.UpdateProduct(p, "someone@tewq.com")
+	is.True(errors.Is(err, ErrTooManySearchTerms))
+}
+
+func TestSearchProducts(t *testing.T) {
+	is := is.New(t)
+	products := []Product{
+		{
+			Name:        "Golf Club",
+			Category:    "Clubs",
+			Description: "A steel driver",
+		},
+		{
+			Name:        "Golf Shoe",
+			Category:    "Shoes",
+			Description: "Waterproof",
+		},
+		{
+			Name:     "Running Shoe",
+			Category: "Shoes",
+		},
+	}
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	// Prepare data to get searched
+	for _, p := range products {
+		_, err := tdb.AddProduct(p)
+		is.NoErr(err)
+	}
+
+	results, err := tdb.SearchProducts(&SearchProductsInput{Query: "golf shoe"})
+	is.NoErr(err)
+	is.True(len(results) == 3)
+	is.Equal(results[0].Product.Name, "Golf Shoe") // Matches both terms, so it ranks first.
+	is.Equal(results[0].Score, 2)
+	is.Equal(results[1].Score, 1)
+
+	results, err = tdb.SearchProducts(&SearchProductsInput{Query: "steel"})
+	is.NoErr(err)
+	is.True(len(results) == 1) // The description is searchable as well.
+	is.Equal(results[0].Product.Name, "Golf Club")
+}
+
+func TestSearchProductsPrefix(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	_, err = tdb.AddProduct(Product{Name: "Golf Driver", Category: "Clubs"})
+	is.NoErr(err)
+	_, err = tdb.AddProduct(Product{Name: "Golf Drink", Category: "Food"})
+	is.NoErr(err)
+
+	results, err := tdb.SearchProducts(&SearchProductsInput{Query: "golf dri"})
+	is.NoErr(err)
+	is.True(len(results) == 2) // Only "golf" matches, without Prefix "dri" has to match a whole term.
+	is.Equal(results[0].Score, 1)
+	is.Equal(results[1].Score, 1)
+
+	results, err = tdb.SearchProducts(&SearchProductsInput{Query: "dri"})
+	is.NoErr(err)
+	is.True(len(results) == 0)
+
+	results, err = tdb.SearchProducts(&SearchProductsInput{Query: "golf dri", Prefix: true})
+	is.NoErr(err)
+	is.True(len(results) == 2)
+
+	results, err = tdb.SearchProducts(&SearchProductsInput{Query: "golf drive", Prefix: true})
+	is.NoErr(err)
+	is.True(len(results) == 2)
+	is.Equal(results[0].Product.Name, "Golf Driver")
+}
+
+func TestDeleteProductRemovesSearchTokens(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+
+	err = tdb.DeleteProduct(p.ID)
+	is.NoErr(err)
+
+	results, err := tdb.SearchProducts(&SearchProductsInput{Query: "golf"})
+	is.NoErr(err)
+	is.True(len(results) == 0)
+}
+
+func TestSearchProductsWithoutTerms(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	_, err = tdb.SearchProducts(&SearchProductsInput{Query: "a !"})
+	is.True(err != nil)
+}
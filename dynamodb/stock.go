@@ -0,0 +1,106 @@
+package dynamodb
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// lowStockPK is the GSI2 partition every option under the low stock threshold lives in.
+// Options above the threshold don't have GSI2 attributes at all, which keeps the index sparse.
+const lowStockPK = "OPTION#LOWSTOCK"
+
+// lowStockSK sorts the low stock index by stock, so out of stock options comes first.
+func lowStockSK(stock int, optionID SortableID) string {
+	return fmt.Sprintf("STOCK#%s#OPTION#%s", zerosPadding(stock), optionID)
+}
+
+func (db *DynamoDB) isLowStock(stock int) bool {
+	return stock < db.lowStockThreshold
+}
+
+// SetOptionStock sets the stock of an option and keeps the low stock index up to date, a negative stock fails with ErrNegativeStock.
+// Setting a stock under the low stock threshold writes a TopicStockLow message to the outbox in the same transaction.
+func (db *DynamoDB) SetOptionStock(productID, optionID SortableID, stock int) (Option, error) {
+	return db.UpdateOption(productID, optionID, UpdateOptionInput{Stock: &stock})
+}
+
+// ListLowStockOptionsInput narrows down which low stock options to list.
+type ListLowStockOptionsInput struct {
+	// Below lists options with a stock under this value, 1 lists the out of stock options.
+	// Defaults to the low stock threshold, and can't be larger since nothing above it is indexed.
+	Below           int
+	PaginationLimit int
+	PreviousKey     PaginationKey
+}
+
+func (db *DynamoDB) validateListLowStockOptionsInput(in *ListLowStockOptionsInput) error {
+	if in.Below > db.lowStockThreshold {
+		return fmt.Errorf("Below (%d) is larger then the low stock threshold (%d).", in.Below, db.lowStockThreshold)
+	}
+
+	if in.Below == 0 {
+		in.Below = db.lowStockThreshold
+	}
+
+	if in.PaginationLimit == 0 {
+		in.PaginationLimit = 20
+	}
+
+	return nil
+}
+
+// ListLowStockOptions lists the options with a stock under the threshold, lowest stock first.
+// Archived options aren't listed, so a page can hold fewer options than the limit while there are more to come.
+func (db *DynamoDB) ListLowStockOptions(input *ListLowStockOptionsInput) ([]Option, PaginationKey, error) {
+	if err := db.validateListLowStockOptionsInput(input); err != nil {
+		return nil, "", err
+	}
+
+	startKey, err := input.PreviousKey.decode()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var result []Option
+	var lastKey PaginationKey
+
+	res, err := db.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(db.tableName),
+		IndexName:              aws.String("GSI2"),
+		KeyConditionExpression: aws.String("#GSI2PK = :gsi2pk And #GSI2SK < :below"),
+		FilterExpression:       aws.String("attribute_not_exists(#Archived)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#GSI2PK":   aws.String("GSI2PK"),
+			"#GSI2SK":   aws.String("GSI2SK"),
+			"#Archived": aws.String("Archived"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":gsi2pk": {
+				S: aws.String(lowStockPK),
+			},
+			":below": {
+				S: aws.String(fmt.Sprintf("STOCK#%s", zerosPadding(input.Below))),
+			},
+		},
+		Limit:             aws.Int64(int64(input.PaginationLimit)),
+		ExclusiveStartKey: startKey,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(res.LastEvaluatedKey, &lastKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(res.Items, &result)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return result, lastKey, nil
+}
@@ -0,0 +1,125 @@
+package dynamodb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestListLowStockOptions(t *testing.T) {
+	is := is.New(t)
+	options := []Option{
+		{
+			Color: "Red",
+			Stock: 0,
+		},
+		{
+			Color: "Green",
+			Stock: 2,
+		},
+		{
+			Color: "Blue",
+			Stock: 100,
+		},
+	}
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	// Prepare data to get fetched
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+	for _, o := range options {
+		_, err := tdb.AddOptionToProduct(p.ID, o)
+		is.NoErr(err)
+	}
+
+	fetched, _, err := tdb.ListLowStockOptions(&ListLowStockOptionsInput{})
+	is.NoErr(err)
+	is.True(len(fetched) == 2)        // Blue has plenty in stock, so it should not be listed.
+	is.Equal(fetched[0].Color, "Red") // Out of stock options comes first.
+	is.Equal(fetched[0].ProductID, p.ID)
+
+	fetched, _, err = tdb.ListLowStockOptions(&ListLowStockOptionsInput{
+		Below: 1,
+	})
+	is.NoErr(err)
+	is.True(len(fetched) == 1) // Only Red is out of stock.
+}
+
+func TestSetOptionStock(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	// Prepare data to get fetched
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Red", Stock: 100})
+	is.NoErr(err)
+
+	fetched, _, err := tdb.ListLowStockOptions(&ListLowStockOptionsInput{})
+	is.NoErr(err)
+	is.True(len(fetched) == 0)
+
+	updated, err := tdb.SetOptionStock(p.ID, o.ID, 1)
+	is.NoErr(err)
+	is.Equal(updated.Stock, 1)
+
+	fetched, _, err = tdb.ListLowStockOptions(&ListLowStockOptionsInput{})
+	is.NoErr(err)
+	is.True(len(fetched) == 1) // The option dropped under the threshold.
+
+	_, err = tdb.SetOptionStock(p.ID, o.ID, 50)
+	is.NoErr(err)
+
+	fetched, _, err = tdb.ListLowStockOptions(&ListLowStockOptionsInput{})
+	is.NoErr(err)
+	is.True(len(fetched) == 0) // The option got restocked, so it should leave the index.
+}
+
+func TestListLowStockOptionsAboveThreshold(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	_, _, err = tdb.ListLowStockOptions(&ListLowStockOptionsInput{
+		Below: DefaultLowStockThreshold + 1,
+	})
+	is.True(err != nil) // Nothing above the threshold is indexed.
+}
+
+func TestNegativeStock(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs"})
+	is.NoErr(err)
+
+	_, err = tdb.AddOptionToProduct(p.ID, Option{Color: "Red", Stock: -1})
+	is.True(errors.Is(err, ErrNegativeStock))
+
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Red", Stock: 2})
+	is.NoErr(err)
+
+	_, err = tdb.SetOptionStock(p.ID, o.ID, -1)
+	is.True(errors.Is(err, ErrNegativeStock))
+
+	stock := -5
+	_, err = tdb.UpdateOption(p.ID, o.ID, UpdateOptionInput{Stock: &stock})
+	is.True(errors.Is(err, ErrNegativeStock))
+
+	fetched, _, err := tdb.ListLowStockOptions(&ListLowStockOptionsInput{})
+	is.NoErr(err)
+	is.True(len(fetched) == 1) // Nothing negative made it into the low stock index.
+	is.Equal(fetched[0].Stock, 2)
+}
@@ -0,0 +1,125 @@
+package dynamodb
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ttlAttribute is the attribute DynamoDB expires items by, in seconds since the epoch.
+const ttlAttribute = "ExpiresAt"
+
+// CreateTable creates the single table with all the indexes the access patterns needs and a stream of its changes,
+// and turns on expiring items by their ExpiresAt once the table exists.
+func (db *DynamoDB) CreateTable() error {
+	_, err := db.db.CreateTable(&dynamodb.CreateTableInput{
+		TableName: aws.String(db.tableName),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("PK"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("SK"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("GSI1PK"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("GSI1SK"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("GSI2PK"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("GSI2SK"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("PK"),
+				KeyType:       aws.String("HASH"),
+			},
+			{
+				AttributeName: aws.String("SK"),
+				KeyType:       aws.String("RANGE"),
+			},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String("GSI1"),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{
+						AttributeName: aws.String("GSI1PK"),
+						KeyType:       aws.String("HASH"),
+					},
+					{
+						AttributeName: aws.String("GSI1SK"),
+						KeyType:       aws.String("RANGE"),
+					},
+				},
+				Projection: &dynamodb.Projection{
+					ProjectionType: aws.String(dynamodb.ProjectionTypeAll),
+				},
+				ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+					ReadCapacityUnits:  aws.Int64(10),
+					WriteCapacityUnits: aws.Int64(10),
+				},
+			},
+			{
+				IndexName: aws.String("GSI2"),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{
+						AttributeName: aws.String("GSI2PK"),
+						KeyType:       aws.String("HASH"),
+					},
+					{
+						AttributeName: aws.String("GSI2SK"),
+						KeyType:       aws.String("RANGE"),
+					},
+				},
+				Projection: &dynamodb.Projection{
+					ProjectionType: aws.String(dynamodb.ProjectionTypeAll),
+				},
+				ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+					ReadCapacityUnits:  aws.Int64(10),
+					WriteCapacityUnits: aws.Int64(10),
+				},
+			},
+		},
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(10),
+			WriteCapacityUnits: aws.Int64(10),
+		},
+		// The stream package decodes events by comparing the old and new images of the items.
+		StreamSpecification: &dynamodb.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: aws.String(dynamodb.StreamViewTypeNewAndOldImages),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The table can't be updated while it's still being created.
+	err = db.db.WaitUntilTableExists(&dynamodb.DescribeTableInput{
+		TableName: aws.String(db.tableName),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(db.tableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(ttlAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+
+	return err
+}
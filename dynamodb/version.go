@@ -0,0 +1,292 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// versionSK is the sort key of version n of a product, padded so the versions sort by number.
+// "VERSION#" sorts after "OPTION$", so GetProduct doesn't read them.
+func versionSK(n int) string {
+	return fmt.Sprintf("VERSION#%010d", n)
+}
+
+// getProductItem fetches the METADATA# item of a product the way it's stored, reading the latest write.
+func (db *DynamoDB) getProductItem(id SortableID) (map[string]*dynamodb.AttributeValue, error) {
+	res, err := db.db.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(db.tableName),
+		Key:            productKey(id),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	return res.Item, nil
+}
+
+// getCurrentProduct fetches the METADATA# item of a product, reading the latest write, without its options.
+func (db *DynamoDB) getCurrentProduct(id SortableID) (Product, error) {
+	item, err := db.getProductItem(id)
+	if err != nil {
+		return Product{}, err
+	}
+
+	var p Product
+	if err := dynamodbattribute.UnmarshalMap(item, &p); err != nil {
+		return Product{}, err
+	}
+
+	return p, nil
+}
+
+// versionOf is the version of a product, products added before they were versioned are at version 1.
+func versionOf(p Product) int {
+	if p.Version == 0 {
+		return 1
+	}
+	return p.Version
+}
+
+// unchangedCondition is a ConditionExpression holding while the product is still at the version of p,
+// adding the names and values it uses to names and values.
+// Products from before they were versioned have no Version yet, until they're updated.
+func unchangedCondition(p Product, names map[string]*string, values map[string]*dynamodb.AttributeValue) string {
+	if p.Version == 0 {
+		names["#PK"] = aws.String("PK")
+		names["#Version"] = aws.String("Version")
+		return "attribute_exists(#PK) And attribute_not_exists(#Version)"
+	}
+	names["#Version"] = aws.String("Version")
+	values[":version"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprint(p.Version))}
+	return "#Version = :version"
+}
+
+// UpdateProduct changes the name, description, category, price, weight and sale of a product to the ones of p,
+// leaving its options, images, ratings and archived state alone. changedBy tells who made the change.
+//
+// The product as it was is kept as a VERSION# item, written in the same transaction as the change together with
+// the search tokens of the terms that changed. Changing more terms than fit in it fails with ErrTooManySearchTerms.
+// When p.Version is set the change only goes through while the product is still at that version,
+// so changes made in between aren't overwritten, otherwise ErrConflict is returned.
+// The returned product doesn't include its options.
+func (db *DynamoDB) UpdateProduct(p Product, changedBy string) (Product, error) {
+	switch {
+	case p.ID == (SortableID{}):
+		return Product{}, errors.New("Expected ID to have a value.")
+	case p.Name == "":
+		return Product{}, errors.New("Expected Name to have a value.")
+	case p.Category == "":
+		return Product{}, errors.New("Expected Category to have a value.")
+	case changedBy == "":
+		return Product{}, errors.New("Expected changedBy to have a value.")
+	}
+	if err := validateProductTerms(p); err != nil {
+		return Product{}, err
+	}
+
+	item, err := db.getProductItem(p.ID)
+	if err != nil {
+		return Product{}, err
+	}
+	var current Product
+	if err := dynamodbattribute.UnmarshalMap(item, &current); err != nil {
+		return Product{}, err
+	}
+	version := versionOf(current)
+	if p.Version != 0 && p.Version != version {
+		return Product{}, ErrConflict
+	}
+
+	snapshot := map[string]*dynamodb.AttributeValue{}
+	for name, av := range item {
+		if strings.HasPrefix(name, "GSI") {
+			continue // Old versions aren't listed anywhere.
+		}
+		snapshot[name] = av
+	}
+	snapshot["SK"] = &dynamodb.AttributeValue{S: aws.String(versionSK(version))}
+	snapshot["Type"] = &dynamodb.AttributeValue{S: aws.String("product_version")}
+	snapshot["Version"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprint(version))}
+
+	updated, err := dynamodbattribute.Marshal(db.now())
+	if err != nil {
+		return Product{}, err
+	}
+	set := []string{"#Version = :next", "#UpdatedUtc = :updated", "#UpdatedBy = :updatedBy"}
+	var remove []string
+	names := map[string]*string{
+		"#Version":    aws.String("Version"),
+		"#UpdatedUtc": aws.String("UpdatedUtc"),
+		"#UpdatedBy":  aws.String("UpdatedBy"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":next":      {N: aws.String(fmt.Sprint(version + 1))},
+		":updated":   updated,
+		":updatedBy": {S: aws.String(changedBy)},
+	}
+
+	// Empty values aren't stored, the same way AddProduct leaves them out.
+	for _, f := range []struct {
+		name string
+		av   *dynamodb.AttributeValue
+		zero bool
+	}{
+		{"Name", &dynamodb.AttributeValue{S: aws.String(p.Name)}, false},
+		{"Description", &dynamodb.AttributeValue{S: aws.String(p.Description)}, p.Description == ""},
+		{"Category", &dynamodb.AttributeValue{S: aws.String(p.Category)}, false},
+		{"Price", &dynamodb.AttributeValue{N: aws.String(fmt.Sprint(p.Price))}, p.Price == 0},
+		{"Weight", &dynamodb.AttributeValue{N: aws.String(fmt.Sprint(p.Weight))}, p.Weight == 0},
+		{"Sale", &dynamodb.AttributeValue{N: aws.String(fmt.Sprint(p.Sale))}, p.Sale == 0},
+	} {
+		names["#"+f.name] = aws.String(f.name)
+		if f.zero {
+			remove = append(remove, "#"+f.name)
+			continue
+		}
+		set = append(set, fmt.Sprintf("#%s = :%s", f.name, strings.ToLower(f.name)))
+		values[":"+strings.ToLower(f.name)] = f.av
+	}
+	if !current.Archived {
+		set = append(set, "#GSI1PK = :gsi1pk", "#GSI1SK = :gsi1sk")
+		names["#GSI1PK"] = aws.String("GSI1PK")
+		names["#GSI1SK"] = aws.String("GSI1SK")
+		values[":gsi1pk"] = &dynamodb.AttributeValue{S: aws.String(categoryPK(p.Category))}
+		values[":gsi1sk"] = &dynamodb.AttributeValue{S: aws.String(zerosPadding(p.Price))}
+	}
+	update := "SET " + strings.Join(set, ", ")
+	if len(remove) > 0 {
+		update += " REMOVE " + strings.Join(remove, ", ")
+	}
+
+	unchanged := unchangedCondition(current, names, values)
+
+	writes := []*dynamodb.TransactWriteItem{
+		{
+			Update: &dynamodb.Update{
+				TableName:                 aws.String(db.tableName),
+				Key:                       productKey(p.ID),
+				ConditionExpression:       aws.String(unchanged),
+				UpdateExpression:          aws.String(update),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		},
+		{
+			Put: &dynamodb.Put{
+				TableName:           aws.String(db.tableName),
+				Item:                snapshot,
+				ConditionExpression: aws.String("attribute_not_exists(#PK)"),
+				ExpressionAttributeNames: map[string]*string{
+					"#PK": aws.String("PK"),
+				},
+			},
+		},
+	}
+	// The search tokens change together with the product, so the search never finds it by what it isn't anymore.
+	p.Archived = current.Archived
+	tokens := db.reindexWrites(current, p)
+	if n := len(writes) + len(tokens); n > transactWriteLimit {
+		return Product{}, fmt.Errorf("%w: the change rewrites %d search terms, at most %d can change at once",
+			ErrTooManySearchTerms, len(tokens), transactWriteLimit-len(writes))
+	}
+	writes = append(writes, tokens...)
+
+	_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: writes})
+	if conditionFailedAt(err, 0) || conditionFailedAt(err, 1) {
+		return Product{}, ErrConflict
+	}
+	if err != nil {
+		return Product{}, err
+	}
+
+	item, err = db.getProductItem(p.ID)
+	if err != nil {
+		return Product{}, err
+	}
+	var changed Product
+	if err := dynamodbattribute.UnmarshalMap(item, &changed); err != nil {
+		return Product{}, err
+	}
+
+	return changed, nil
+}
+
+// GetProductHistory fetches every version of a product, the current one first.
+// The products don't include their options.
+func (db *DynamoDB) GetProductHistory(id SortableID) ([]Product, error) {
+	current, err := db.getCurrentProduct(id)
+	if err != nil {
+		return nil, err
+	}
+	current.Version = versionOf(current)
+
+	res, err := db.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(db.tableName),
+		KeyConditionExpression: aws.String("#PK = :pk And begins_with(#SK, :versions)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#PK": aws.String("PK"),
+			"#SK": aws.String("SK"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": productKey(id)["PK"],
+			":versions": {
+				S: aws.String("VERSION#"),
+			},
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Product
+	err = dynamodbattribute.UnmarshalListOfMaps(res.Items, &versions)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]Product{current}, versions...), nil
+}
+
+// RevertProduct changes a product back to what it was at version, as a new version made by changedBy.
+// Reverting to the current version does nothing, and a version that doesn't exist is ErrNotFound.
+// The returned product doesn't include its options.
+func (db *DynamoDB) RevertProduct(id SortableID, version int, changedBy string) (Product, error) {
+	current, err := db.getCurrentProduct(id)
+	if err != nil {
+		return Product{}, err
+	}
+	if version == versionOf(current) {
+		return current, nil
+	}
+
+	key := productKey(id)
+	key["SK"] = &dynamodb.AttributeValue{S: aws.String(versionSK(version))}
+	res, err := db.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(db.tableName),
+		Key:       key,
+	})
+	if err != nil {
+		return Product{}, err
+	}
+	if res.Item == nil {
+		return Product{}, ErrNotFound
+	}
+
+	var old Product
+	if err := dynamodbattribute.UnmarshalMap(res.Item, &old); err != nil {
+		return Product{}, err
+	}
+	old.Version = versionOf(current) // Only if nothing changed since reading it.
+
+	return db.UpdateProduct(old, changedBy)
+}
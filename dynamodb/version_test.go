@@ -0,0 +1,136 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestUpdateProduct(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Blue Driver", Category: "Clubs", Price: 300})
+	is.NoErr(err)
+	is.Equal(p.Version, 1)
+	_, err = tdb.AddOptionToProduct(p.ID, Option{Size: "Regular"})
+	is.NoErr(err)
+
+	p.Name = "Red Driver"
+	p.Price = 250
+	updated, err := tdb.UpdateProduct(p, "anna@tewq.com")
+	is.NoErr(err)
+	is.Equal(updated.Version, 2)
+	is.Equal(updated.Price, 250)
+	is.Equal(updated.UpdatedBy, "anna@tewq.com")
+	is.True(updated.UpdatedDate != nil)
+
+	// p was read at version 1, which isn't current anymore.
+	_, err = tdb.UpdateProduct(p, "bob@tewq.com")
+	is.Equal(err, ErrConflict)
+
+	fetched, err := tdb.GetProduct(p.ID)
+	is.NoErr(err)
+	is.Equal(fetched.Name, "Red Driver")
+	is.Equal(len(fetched.Options), 1) // Versions aren't mistaken for options.
+
+	listed, _, err := tdb.GetProductsByCategory(&GetProductsByCategoryInput{Category: "Clubs", FromPrice: 250, ToPrice: 250})
+	is.NoErr(err)
+	is.Equal(len(listed), 1)
+
+	found, err := tdb.SearchProducts(&SearchProductsInput{Query: "blue"})
+	is.NoErr(err)
+	is.Equal(len(found), 0)
+	found, err = tdb.SearchProducts(&SearchProductsInput{Query: "red driver"})
+	is.NoErr(err)
+	is.Equal(len(found), 1)
+	is.Equal(found[0].Score, 2)
+}
+
+func TestUpdateProductTooManyChangedTerms(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	describe := func(word string) string {
+		var description strings.Builder
+		for i := 0; i < 60; i++ {
+			fmt.Fprintf(&description, "%s%d ", word, i)
+		}
+		return description.String()
+	}
+
+	p, err := tdb.AddProduct(Product{Name: "Golf Club", Category: "Clubs", Description: describe("old")})
+	is.NoErr(err)
+
+	// 60 tokens deleted and 60 put don't fit in a transaction with the change.
+	p.Description = describe("new")
+	_, err = tdb.UpdateProduct(p, "someone@tewq.com")
+	is.True(errors.Is(err, ErrTooManySearchTerms))
+
+	found, err := tdb.SearchProducts(&SearchProductsInput{Query: "old1"})
+	is.NoErr(err)
+	is.Equal(len(found), 1) // Nothing changed.
+
+	// Only the terms that change count, the name is all that does here.
+	p.Description = describe("old")
+	p.Name = "Golf Driver"
+	_, err = tdb.UpdateProduct(p, "someone@tewq.com")
+	is.NoErr(err)
+
+	found, err = tdb.SearchProducts(&SearchProductsInput{Query: "club"})
+	is.NoErr(err)
+	is.Equal(len(found), 0)
+	found, err = tdb.SearchProducts(&SearchProductsInput{Query: "driver old1"})
+	is.NoErr(err)
+	is.Equal(len(found), 1)
+}
+
+func TestProductHistoryAndRevert(t *testing.T) {
+	is := is.New(t)
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Putter", Category: "Clubs", Price: 100})
+	is.NoErr(err)
+	p.Price = 80
+	p, err = tdb.UpdateProduct(p, "anna@tewq.com")
+	is.NoErr(err)
+	p.Price = 60
+	p, err = tdb.UpdateProduct(p, "bob@tewq.com")
+	is.NoErr(err)
+
+	history, err := tdb.GetProductHistory(p.ID)
+	is.NoErr(err)
+	is.Equal(len(history), 3)
+	is.Equal(history[0].Version, 3)
+	is.Equal(history[0].Price, 60)
+	is.Equal(history[0].UpdatedBy, "bob@tewq.com")
+	is.Equal(history[1].Version, 2)
+	is.Equal(history[1].Price, 80)
+	is.Equal(history[1].UpdatedBy, "anna@tewq.com")
+	is.Equal(history[2].Version, 1)
+	is.Equal(history[2].Price, 100)
+
+	reverted, err := tdb.RevertProduct(p.ID, 1, "carl@tewq.com")
+	is.NoErr(err)
+	is.Equal(reverted.Version, 4)
+	is.Equal(reverted.Price, 100)
+	is.Equal(reverted.UpdatedBy, "carl@tewq.com")
+
+	_, err = tdb.RevertProduct(p.ID, 9, "carl@tewq.com")
+	is.Equal(err, ErrNotFound)
+
+	_, err = tdb.GetProductHistory(NewSortableID())
+	is.Equal(err, ErrNotFound)
+}
@@ -0,0 +1,147 @@
+package dynamodb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// WishlistItem is a product a customer saved for later.
+type WishlistItem struct {
+	CustomerID SortableID `json:"customerId" dynamodbav:"CustomerId"`
+	ProductID  SortableID `json:"productId" dynamodbav:"ProductId"`
+	AddedDate  time.Time  `json:"addedUtc" dynamodbav:"AddedUtc,omitempty"`
+}
+
+// wishlistItemKey is the key of a product in the wishlist of a customer.
+// A product is only ever once in a wishlist, so it's keyed by the product id.
+func wishlistItemKey(customerID, productID SortableID) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {
+			S: aws.String(fmt.Sprintf("WISHLIST#%s", customerID)),
+		},
+		"SK": {
+			S: aws.String(fmt.Sprintf("PRODUCT#%s", productID)),
+		},
+	}
+}
+
+// AddToWishlist saves a product in the wishlist of a customer.
+// Adding a product that's already in the wishlist changes nothing.
+func (db *DynamoDB) AddToWishlist(customerID, productID SortableID) error {
+	item, err := dynamodbattribute.MarshalMap(&WishlistItem{
+		CustomerID: customerID,
+		ProductID:  productID,
+		AddedDate:  db.now(),
+	})
+	if err != nil {
+		return err
+	}
+	for name, av := range wishlistItemKey(customerID, productID) {
+		item[name] = av
+	}
+	item["Type"] = &dynamodb.AttributeValue{S: aws.String("wishlist_item")}
+
+	_, err = db.db.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(db.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#PK)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#PK": aws.String("PK"),
+		},
+	})
+	if isConditionFailed(err) {
+		return nil // Keeps when it was first added.
+	}
+
+	return err
+}
+
+// RemoveFromWishlist takes a product out of the wishlist of a customer.
+func (db *DynamoDB) RemoveFromWishlist(customerID, productID SortableID) error {
+	_, err := db.db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(db.tableName),
+		Key:       wishlistItemKey(customerID, productID),
+	})
+	return err
+}
+
+// GetWishlist fetches the products in the wishlist of a customer, without their options.
+// Products that don't exist anymore are left out.
+func (db *DynamoDB) GetWishlist(customerID SortableID) ([]Product, error) {
+	items, err := db.queryPartition(fmt.Sprintf("WISHLIST#%s", customerID))
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	var wishlist []WishlistItem
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &wishlist)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]SortableID, 0, len(wishlist))
+	for _, item := range wishlist {
+		ids = append(ids, item.ProductID)
+	}
+
+	return db.batchGetProducts(ids)
+}
+
+// MoveWishlistItemToBasket takes a product out of the wishlist of a customer and puts the option of it in their basket,
+// both in the same transaction. It fails with ErrNotFound when the product isn't in the wishlist,
+// or the option isn't one of the product, and with ErrArchived when the option is archived.
+func (db *DynamoDB) MoveWishlistItemToBasket(customerID, productID, optionID SortableID) error {
+	item := BasketItem{
+		ID:              db.newID(),
+		CustomerID:      customerID,
+		ProductID:       productID,
+		ProductOptionID: optionID,
+		Quantity:        1,
+		ExpiresAt:       db.basketExpiry(db.now()),
+	}
+	i, err := basketItem(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					TableName:           aws.String(db.tableName),
+					Key:                 wishlistItemKey(customerID, productID),
+					ConditionExpression: aws.String("attribute_exists(#PK)"),
+					ExpressionAttributeNames: map[string]*string{
+						"#PK": aws.String("PK"),
+					},
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName: aws.String(db.tableName),
+					Item:      i,
+				},
+			},
+			// The option is keyed by the product, so it can't belong to another one.
+			db.holdOptionItem(productID, optionID, item.ExpiresAt),
+		},
+	})
+	if conditionFailedAt(err, 0) {
+		return ErrNotFound
+	}
+	if conditionFailedAt(err, 2) {
+		return db.optionUnavailable(productID, optionID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return db.touchBasket(item.basketPK(), item.ExpiresAt)
+}
@@ -0,0 +1,104 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestWishlist(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	putter, err := tdb.AddProduct(Product{Name: "Putter", Category: "Clubs"})
+	is.NoErr(err)
+	bag, err := tdb.AddProduct(Product{Name: "Bag", Category: "Bags"})
+	is.NoErr(err)
+
+	is.NoErr(tdb.AddToWishlist(customerID, putter.ID))
+	is.NoErr(tdb.AddToWishlist(customerID, bag.ID))
+	is.NoErr(tdb.AddToWishlist(customerID, bag.ID)) // Already there.
+
+	products, err := tdb.GetWishlist(customerID)
+	is.NoErr(err)
+	is.Equal(len(products), 2)
+
+	is.NoErr(tdb.RemoveFromWishlist(customerID, bag.ID))
+
+	products, err = tdb.GetWishlist(customerID)
+	is.NoErr(err)
+	is.Equal(len(products), 1)
+	is.Equal(products[0].Name, "Putter")
+}
+
+func TestMoveWishlistItemToBasket(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Cap", Category: "Clothes"})
+	is.NoErr(err)
+	o, err := tdb.AddOptionToProduct(p.ID, Option{Color: "White", Stock: 4})
+	is.NoErr(err)
+
+	is.NoErr(tdb.AddToWishlist(customerID, p.ID))
+	is.NoErr(tdb.MoveWishlistItemToBasket(customerID, p.ID, o.ID))
+
+	err = tdb.MoveWishlistItemToBasket(customerID, p.ID, o.ID)
+	is.Equal(err, ErrNotFound) // It's not in the wishlist anymore.
+
+	wishlist, err := tdb.GetWishlist(customerID)
+	is.NoErr(err)
+	is.Equal(len(wishlist), 0)
+
+	items, err := tdb.GetBasketItems(customerID)
+	is.NoErr(err)
+	is.Equal(len(items), 1)
+	is.Equal(items[0].ProductOptionID, o.ID)
+}
+
+func TestMoveWishlistItemToBasketUnknownOption(t *testing.T) {
+	is := is.New(t)
+	customerID := NewSortableID()
+
+	tdb, err := NewTestDynamoDB()
+	is.NoErr(err)
+	defer tdb.Close()
+
+	p, err := tdb.AddProduct(Product{Name: "Cap", Category: "Clothes"})
+	is.NoErr(err)
+	other, err := tdb.AddProduct(Product{Name: "Glove", Category: "Clothes"})
+	is.NoErr(err)
+	otherOption, err := tdb.AddOptionToProduct(other.ID, Option{Color: "Black", Stock: 2})
+	is.NoErr(err)
+	archived, err := tdb.AddOptionToProduct(p.ID, Option{Color: "Red", Stock: 2})
+	is.NoErr(err)
+	_, err = tdb.ArchiveOption(p.ID, archived.ID)
+	is.NoErr(err)
+
+	is.NoErr(tdb.AddToWishlist(customerID, p.ID))
+
+	err = tdb.MoveWishlistItemToBasket(customerID, p.ID, NewSortableID())
+	is.Equal(err, ErrNotFound)
+
+	err = tdb.MoveWishlistItemToBasket(customerID, p.ID, otherOption.ID)
+	is.Equal(err, ErrNotFound) // The option belongs to another product.
+
+	err = tdb.MoveWishlistItemToBasket(customerID, p.ID, archived.ID)
+	is.Equal(err, ErrArchived)
+
+	wishlist, err := tdb.GetWishlist(customerID)
+	is.NoErr(err)
+	is.Equal(len(wishlist), 1) // Still in the wishlist, since nothing got moved.
+
+	items, err := tdb.GetBasketItems(customerID)
+	is.NoErr(err)
+	is.Equal(len(items), 0)
+}
@@ -3,6 +3,7 @@ module github.com/Tinee/tewq
 go 1.15
 
 require (
+	github.com/aws/aws-lambda-go v1.19.1
 	github.com/aws/aws-sdk-go v1.34.15
 	github.com/matryer/is v1.4.0
 	github.com/segmentio/ksuid v1.0.3
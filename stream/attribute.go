@@ -0,0 +1,62 @@
+package stream
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// attributeValues turns an image of a stream record into an item the dynamodbattribute package decodes,
+// so the domain types decode from the stream exactly as they do from the table.
+func attributeValues(image map[string]events.DynamoDBAttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	item := make(map[string]*dynamodb.AttributeValue, len(image))
+	for name, v := range image {
+		av, err := attributeValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+		item[name] = av
+	}
+	return item, nil
+}
+
+func attributeValue(v events.DynamoDBAttributeValue) (*dynamodb.AttributeValue, error) {
+	switch v.DataType() {
+	case events.DataTypeString:
+		return &dynamodb.AttributeValue{S: aws.String(v.String())}, nil
+	case events.DataTypeNumber:
+		return &dynamodb.AttributeValue{N: aws.String(v.Number())}, nil
+	case events.DataTypeBinary:
+		return &dynamodb.AttributeValue{B: v.Binary()}, nil
+	case events.DataTypeBoolean:
+		return &dynamodb.AttributeValue{BOOL: aws.Bool(v.Boolean())}, nil
+	case events.DataTypeNull:
+		return &dynamodb.AttributeValue{NULL: aws.Bool(true)}, nil
+	case events.DataTypeStringSet:
+		return &dynamodb.AttributeValue{SS: aws.StringSlice(v.StringSet())}, nil
+	case events.DataTypeNumberSet:
+		return &dynamodb.AttributeValue{NS: aws.StringSlice(v.NumberSet())}, nil
+	case events.DataTypeBinarySet:
+		return &dynamodb.AttributeValue{BS: v.BinarySet()}, nil
+	case events.DataTypeList:
+		l := make([]*dynamodb.AttributeValue, 0, len(v.List()))
+		for _, e := range v.List() {
+			av, err := attributeValue(e)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, av)
+		}
+		return &dynamodb.AttributeValue{L: l}, nil
+	case events.DataTypeMap:
+		m, err := attributeValues(v.Map())
+		if err != nil {
+			return nil, err
+		}
+		return &dynamodb.AttributeValue{M: m}, nil
+	}
+
+	return nil, fmt.Errorf("unknown data type %v", v.DataType())
+}
@@ -0,0 +1,227 @@
+// Package stream turns the records of the DynamoDB stream of the table into typed domain events,
+// and hands them to the handlers registered for them.
+package stream
+
+import (
+	"fmt"
+
+	"github.com/Tinee/tewq/dynamodb"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Event is a change to the store, decoded from a stream record.
+type Event interface {
+	// EventName is what handlers are registered for, like "ProductCreated".
+	EventName() string
+}
+
+// ProductCreated is a product being added.
+type ProductCreated struct{ Product dynamodb.Product }
+
+// ProductUpdated is a product changing, like getting reviewed or a new image.
+type ProductUpdated struct{ Old, New dynamodb.Product }
+
+// ProductDeleted is a product being deleted.
+type ProductDeleted struct{ Product dynamodb.Product }
+
+// OptionCreated is an option being added to a product.
+type OptionCreated struct{ Option dynamodb.Option }
+
+// OptionStockChanged is the stock of an option changing.
+type OptionStockChanged struct {
+	Option   dynamodb.Option // As it is now.
+	OldStock int
+}
+
+// OptionDeleted is an option being deleted.
+type OptionDeleted struct{ Option dynamodb.Option }
+
+// BasketItemAdded is an item being put in a basket.
+type BasketItemAdded struct{ Item dynamodb.BasketItem }
+
+// BasketItemRemoved is an item leaving a basket, Expired telling it was deleted by the basket expiring.
+type BasketItemRemoved struct {
+	Item    dynamodb.BasketItem
+	Expired bool
+}
+
+// ReviewAdded is a review being written, it's pending moderation.
+type ReviewAdded struct{ Review dynamodb.Review }
+
+// ReviewModerated is a review being approved or rejected.
+type ReviewModerated struct{ Review dynamodb.Review }
+
+// OrderPlaced is an order being placed.
+type OrderPlaced struct{ Order dynamodb.Order }
+
+// OrderStatusChanged is an order moving on to another status.
+type OrderStatusChanged struct {
+	Order dynamodb.Order // With the new status.
+	From  dynamodb.OrderStatus
+}
+
+func (ProductCreated) EventName() string     { return "ProductCreated" }
+func (ProductUpdated) EventName() string     { return "ProductUpdated" }
+func (ProductDeleted) EventName() string     { return "ProductDeleted" }
+func (OptionCreated) EventName() string      { return "OptionCreated" }
+func (OptionStockChanged) EventName() string { return "OptionStockChanged" }
+func (OptionDeleted) EventName() string      { return "OptionDeleted" }
+func (BasketItemAdded) EventName() string    { return "BasketItemAdded" }
+func (BasketItemRemoved) EventName() string  { return "BasketItemRemoved" }
+func (ReviewAdded) EventName() string        { return "ReviewAdded" }
+func (ReviewModerated) EventName() string    { return "ReviewModerated" }
+func (OrderPlaced) EventName() string        { return "OrderPlaced" }
+func (OrderStatusChanged) EventName() string { return "OrderStatusChanged" }
+
+// The eventName of stream records.
+const (
+	insert = "INSERT"
+	modify = "MODIFY"
+	remove = "REMOVE"
+)
+
+// images are the old and new image of a record, decoding into the type of the item.
+type images struct {
+	record events.DynamoDBEventRecord
+}
+
+func (im images) old(v interface{}) error { return decodeImage(im.record.Change.OldImage, v) }
+func (im images) new(v interface{}) error { return decodeImage(im.record.Change.NewImage, v) }
+
+func decodeImage(image map[string]events.DynamoDBAttributeValue, v interface{}) error {
+	item, err := attributeValues(image)
+	if err != nil {
+		return err
+	}
+	return dynamodbattribute.UnmarshalMap(item, v)
+}
+
+// Decode turns a stream record into the event it stands for, by the Type attribute written on every item.
+// Records of items nobody listens for, like search tokens, and changes that aren't events, like a basket being touched,
+// decode to a nil Event. The stream has to include the new and old images.
+func Decode(record events.DynamoDBEventRecord) (Event, error) {
+	image := record.Change.NewImage
+	if record.EventName == remove {
+		image = record.Change.OldImage
+	}
+	typ, ok := image["Type"]
+	if !ok || typ.DataType() != events.DataTypeString {
+		return nil, nil
+	}
+
+	im := images{record}
+	e, err := decode(typ.String(), record, im)
+	if err != nil {
+		return nil, fmt.Errorf("record %s: %w", record.EventID, err)
+	}
+	return e, nil
+}
+
+func decode(typ string, record events.DynamoDBEventRecord, im images) (Event, error) {
+	switch typ {
+	case "product":
+		switch record.EventName {
+		case insert:
+			var e ProductCreated
+			err := im.new(&e.Product)
+			return e, err
+		case modify:
+			var e ProductUpdated
+			if err := im.old(&e.Old); err != nil {
+				return nil, err
+			}
+			err := im.new(&e.New)
+			return e, err
+		case remove:
+			var e ProductDeleted
+			err := im.old(&e.Product)
+			return e, err
+		}
+
+	case "product_option":
+		switch record.EventName {
+		case insert:
+			var e OptionCreated
+			err := im.new(&e.Option)
+			return e, err
+		case modify:
+			var old dynamodb.Option
+			if err := im.old(&old); err != nil {
+				return nil, err
+			}
+			e := OptionStockChanged{OldStock: old.Stock}
+			if err := im.new(&e.Option); err != nil {
+				return nil, err
+			}
+			if e.Option.Stock == e.OldStock {
+				return nil, nil
+			}
+			return e, nil
+		case remove:
+			var e OptionDeleted
+			err := im.old(&e.Option)
+			return e, err
+		}
+
+	case "BasketItem":
+		switch record.EventName {
+		case insert:
+			var e BasketItemAdded
+			err := im.new(&e.Item)
+			return e, err
+		case remove:
+			// TTL deletes are made by the DynamoDB service itself.
+			identity := record.UserIdentity
+			e := BasketItemRemoved{
+				Expired: identity != nil && identity.Type == "Service" && identity.PrincipalID == "dynamodb.amazonaws.com",
+			}
+			err := im.old(&e.Item)
+			return e, err
+		}
+
+	case "review":
+		switch record.EventName {
+		case insert:
+			var e ReviewAdded
+			err := im.new(&e.Review)
+			return e, err
+		case modify:
+			var old dynamodb.Review
+			if err := im.old(&old); err != nil {
+				return nil, err
+			}
+			var e ReviewModerated
+			if err := im.new(&e.Review); err != nil {
+				return nil, err
+			}
+			if old.Status == e.Review.Status {
+				return nil, nil
+			}
+			return e, nil
+		}
+
+	case "order":
+		switch record.EventName {
+		case insert:
+			var e OrderPlaced
+			err := im.new(&e.Order)
+			return e, err
+		case modify:
+			var old dynamodb.Order
+			if err := im.old(&old); err != nil {
+				return nil, err
+			}
+			e := OrderStatusChanged{From: old.Status}
+			if err := im.new(&e.Order); err != nil {
+				return nil, err
+			}
+			if e.Order.Status == e.From {
+				return nil, nil
+			}
+			return e, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/Tinee/tewq/dynamodb"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/matryer/is"
+)
+
+// loadEvent reads a recorded batch of stream records from testdata.
+func loadEvent(t *testing.T, name string) events.DynamoDBEvent {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event events.DynamoDBEvent
+	if err := json.Unmarshal(b, &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestDecodeProductCreated(t *testing.T) {
+	is := is.New(t)
+	records := loadEvent(t, "product_created.json").Records
+
+	e, err := Decode(records[0])
+	is.NoErr(err)
+	created, ok := e.(ProductCreated)
+	is.True(ok)
+	is.Equal(created.Product.ID.String(), "3KtnyZSaBpM8c5m0MBX2WxYaamN")
+	is.Equal(created.Product.Name, "Driver")
+	is.Equal(created.Product.Price, 300)
+
+	e, err = Decode(records[1])
+	is.NoErr(err)
+	is.Equal(e, nil) // Search tokens aren't events.
+}
+
+func TestDecodeOptionStockChanged(t *testing.T) {
+	is := is.New(t)
+	record := loadEvent(t, "option_stock_changed.json").Records[0]
+
+	e, err := Decode(record)
+	is.NoErr(err)
+	changed, ok := e.(OptionStockChanged)
+	is.True(ok)
+	is.Equal(changed.OldStock, 12)
+	is.Equal(changed.Option.Stock, 3)
+	is.Equal(changed.Option.Color, "Red")
+}
+
+func TestDecodeBasketItemExpired(t *testing.T) {
+	is := is.New(t)
+	record := loadEvent(t, "basket_item_expired.json").Records[0]
+
+	e, err := Decode(record)
+	is.NoErr(err)
+	removed, ok := e.(BasketItemRemoved)
+	is.True(ok)
+	is.True(removed.Expired) // Deleted by the TTL.
+	is.Equal(removed.Item.Quantity, 2)
+
+	record.UserIdentity = nil
+	e, err = Decode(record)
+	is.NoErr(err)
+	is.True(!e.(BasketItemRemoved).Expired) // Deleted by checking out, say.
+}
+
+func TestDecodeOrderStatusChanged(t *testing.T) {
+	is := is.New(t)
+	record := events.DynamoDBEventRecord{
+		EventID:   "1",
+		EventName: "MODIFY",
+		Change: events.DynamoDBStreamRecord{
+			OldImage: map[string]events.DynamoDBAttributeValue{
+				"Type":   events.NewStringAttribute("order"),
+				"Status": events.NewStringAttribute("placed"),
+			},
+			NewImage: map[string]events.DynamoDBAttributeValue{
+				"Type":   events.NewStringAttribute("order"),
+				"Status": events.NewStringAttribute("paid"),
+			},
+		},
+	}
+
+	e, err := Decode(record)
+	is.NoErr(err)
+	changed, ok := e.(OrderStatusChanged)
+	is.True(ok)
+	is.Equal(changed.From, dynamodb.OrderPlaced)
+	is.Equal(changed.Order.Status, dynamodb.OrderPaid)
+}
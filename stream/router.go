@@ -0,0 +1,57 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandlerFunc handles an event, type asserting it to the event it was registered for.
+type HandlerFunc func(ctx context.Context, e Event) error
+
+// Router hands the events decoded from stream records to the handlers registered for them.
+type Router struct {
+	handlers map[string][]HandlerFunc
+}
+
+// NewRouter creates a Router without any handlers.
+func NewRouter() *Router {
+	return &Router{handlers: map[string][]HandlerFunc{}}
+}
+
+// On registers h for the events named name, like "ProductCreated".
+// Handlers of the same event run in the order they got registered.
+func (r *Router) On(name string, h HandlerFunc) {
+	r.handlers[name] = append(r.handlers[name], h)
+}
+
+// Dispatch decodes record and runs the handlers of its event, stopping at the first one failing.
+func (r *Router) Dispatch(ctx context.Context, record events.DynamoDBEventRecord) error {
+	e, err := Decode(record)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		return nil
+	}
+
+	for _, h := range r.handlers[e.EventName()] {
+		if err := h(ctx, e); err != nil {
+			return fmt.Errorf("%s of record %s: %w", e.EventName(), record.EventID, err)
+		}
+	}
+
+	return nil
+}
+
+// HandleDynamoDBEvent is the Lambda handler for a batch of stream records, dispatching them in order.
+// A failing record fails the batch, which Lambda retries from the start, so handlers have to be idempotent.
+func (r *Router) HandleDynamoDBEvent(ctx context.Context, event events.DynamoDBEvent) error {
+	for _, record := range event.Records {
+		if err := r.Dispatch(ctx, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestRouterHandleDynamoDBEvent(t *testing.T) {
+	is := is.New(t)
+
+	var created, lowStock []string
+	r := NewRouter()
+	r.On("ProductCreated", func(ctx context.Context, e Event) error {
+		created = append(created, e.(ProductCreated).Product.Name)
+		return nil
+	})
+	r.On("OptionStockChanged", func(ctx context.Context, e Event) error {
+		if o := e.(OptionStockChanged).Option; o.Stock < 5 {
+			lowStock = append(lowStock, o.Color)
+		}
+		return nil
+	})
+
+	is.NoErr(r.HandleDynamoDBEvent(context.Background(), loadEvent(t, "product_created.json")))
+	is.NoErr(r.HandleDynamoDBEvent(context.Background(), loadEvent(t, "option_stock_changed.json")))
+	is.NoErr(r.HandleDynamoDBEvent(context.Background(), loadEvent(t, "basket_item_expired.json"))) // Nobody listens.
+
+	is.Equal(created, []string{"Driver"})
+	is.Equal(lowStock, []string{"Red"})
+}
+
+func TestRouterFailingHandler(t *testing.T) {
+	is := is.New(t)
+	boom := errors.New("boom")
+
+	r := NewRouter()
+	r.On("ProductCreated", func(ctx context.Context, e Event) error { return boom })
+
+	err := r.HandleDynamoDBEvent(context.Background(), loadEvent(t, "product_created.json"))
+	is.True(errors.Is(err, boom)) // Fails the batch, so Lambda retries it.
+}